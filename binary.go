@@ -0,0 +1,306 @@
+package stable
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Compression defines compression of binary snapshot.
+type Compression byte
+
+const (
+	// CompressionNone writes binary snapshot as is.
+	CompressionNone Compression = iota
+	// CompressionGzip compresses binary snapshot with gzip.
+	CompressionGzip
+)
+
+// Binary snapshot consists of the header: magic, format version and compression,
+// the body: string dictionary, STable definition and rows with strings replaced by dictionary indexes,
+// and the trailer: CRC32 of uncompressed body.
+// Numbers are written as uvarints, strings as uvarint length followed by bytes.
+const (
+	binaryMagic   = "STBL"
+	binaryVersion = 1
+)
+
+// ErrSnapshotChecksum is returned by Load when binary snapshot is corrupt.
+var ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+
+func (st *stable) WriteBinaryTo(w io.Writer, compression Compression) (int64, error) {
+	if compression != CompressionNone && compression != CompressionGzip {
+		return 0, fmt.Errorf("unsupported compression %v", compression)
+	}
+	s := st.snapshot()
+	cw := &countingWriter{w: w}
+	_, err := cw.Write(append([]byte(binaryMagic), binaryVersion, byte(compression)))
+	if err != nil {
+		return cw.n, err
+	}
+	var body io.Writer = cw
+	var zw *gzip.Writer
+	if compression == CompressionGzip {
+		zw = gzip.NewWriter(cw)
+		body = zw
+	}
+	bw := bufio.NewWriter(body)
+	crc := crc32.NewIEEE()
+	err = writeBinarySnapshot(io.MultiWriter(bw, crc), s)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return cw.n, err
+	}
+	_, err = cw.Write(crc.Sum(nil))
+	return cw.n, err
+}
+
+func writeBinarySnapshot(w io.Writer, s snapshot) error {
+	dict := newStringDict()
+	dict.add(s.Name, s.PrimaryKeyField)
+	dict.add(s.NonEmptyFields...)
+	dict.add(s.UniqFields...)
+	for _, row := range s.Rows {
+		for field, value := range row {
+			dict.add(field, value)
+		}
+	}
+	bw := &binaryWriter{w: w}
+	bw.uvarint(uint64(len(dict.values)))
+	for _, value := range dict.values {
+		bw.string(value)
+	}
+	bw.uvarint(dict.indexes[s.Name])
+	bw.uvarint(dict.indexes[s.PrimaryKeyField])
+	for _, fields := range [][]string{s.NonEmptyFields, s.UniqFields} {
+		bw.uvarint(uint64(len(fields)))
+		for _, field := range fields {
+			bw.uvarint(dict.indexes[field])
+		}
+	}
+	bw.uvarint(s.Seq)
+	bw.uvarint(uint64(len(s.Rows)))
+	for _, row := range s.Rows {
+		bw.uvarint(uint64(len(row)))
+		for field, value := range row {
+			bw.uvarint(dict.indexes[field])
+			bw.uvarint(dict.indexes[value])
+		}
+	}
+	return bw.err
+}
+
+func readBinarySnapshot(r *bufio.Reader) (snapshot, error) {
+	header := make([]byte, len(binaryMagic)+2)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return snapshot{}, err
+	}
+	version, compression := header[len(binaryMagic)], Compression(header[len(binaryMagic)+1])
+	if version != binaryVersion {
+		return snapshot{}, fmt.Errorf("unsupported snapshot version %v", version)
+	}
+	body := r
+	var zr *gzip.Reader
+	switch compression {
+	case CompressionNone:
+	case CompressionGzip:
+		zr, err = gzip.NewReader(r)
+		if err != nil {
+			return snapshot{}, err
+		}
+		// the trailer follows gzip stream
+		zr.Multistream(false)
+		body = bufio.NewReader(zr)
+	default:
+		return snapshot{}, fmt.Errorf("unsupported compression %v", compression)
+	}
+	br := &binaryReader{r: body, crc: crc32.NewIEEE()}
+	s := br.snapshot()
+	if br.err != nil {
+		return snapshot{}, br.err
+	}
+	if zr != nil {
+		// the body must end with gzip stream, reading to its end verifies gzip checksum
+		n, err := io.Copy(io.Discard, body)
+		if err != nil {
+			return snapshot{}, err
+		}
+		if n != 0 {
+			return snapshot{}, errors.New("unexpected data after snapshot")
+		}
+	}
+	trailer := make([]byte, crc32.Size)
+	_, err = io.ReadFull(r, trailer)
+	if err != nil {
+		return snapshot{}, err
+	}
+	if binary.BigEndian.Uint32(trailer) != br.crc.Sum32() {
+		return snapshot{}, ErrSnapshotChecksum
+	}
+	return s, nil
+}
+
+type stringDict struct {
+	values  []string
+	indexes map[string]uint64
+}
+
+func newStringDict() *stringDict {
+	return &stringDict{indexes: make(map[string]uint64)}
+}
+
+func (d *stringDict) add(values ...string) {
+	for _, value := range values {
+		if _, ok := d.indexes[value]; ok {
+			continue
+		}
+		d.indexes[value] = uint64(len(d.values))
+		d.values = append(d.values, value)
+	}
+}
+
+type binaryWriter struct {
+	w   io.Writer
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func (bw *binaryWriter) uvarint(v uint64) {
+	if bw.err != nil {
+		return
+	}
+	n := binary.PutUvarint(bw.buf[:], v)
+	_, bw.err = bw.w.Write(bw.buf[:n])
+}
+
+func (bw *binaryWriter) string(s string) {
+	bw.uvarint(uint64(len(s)))
+	if bw.err != nil {
+		return
+	}
+	_, bw.err = io.WriteString(bw.w, s)
+}
+
+// binaryReader reads binary snapshot body and calculates its checksum.
+type binaryReader struct {
+	r    *bufio.Reader
+	crc  hash.Hash32
+	dict []string
+	err  error
+}
+
+// ReadByte reads one byte for binary.ReadUvarint.
+func (br *binaryReader) ReadByte() (byte, error) {
+	b, err := br.r.ReadByte()
+	if err == nil {
+		_, _ = br.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (br *binaryReader) snapshot() snapshot {
+	n := br.count()
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		br.dict = append(br.dict, br.string())
+	}
+	s := snapshot{Version: snapshotVersion}
+	s.Name = br.ref()
+	s.PrimaryKeyField = br.ref()
+	s.NonEmptyFields = br.refs()
+	s.UniqFields = br.refs()
+	s.Seq = br.uvarint()
+	n = br.count()
+	s.Rows = make([]map[string]string, 0, minUint64(n, 1<<16))
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		fields := br.count()
+		row := make(map[string]string, minUint64(fields, 1<<8))
+		for j := uint64(0); j < fields && br.err == nil; j++ {
+			field := br.ref()
+			row[field] = br.ref()
+		}
+		s.Rows = append(s.Rows, row)
+	}
+	return s
+}
+
+func (br *binaryReader) uvarint() uint64 {
+	if br.err != nil {
+		return 0
+	}
+	var v uint64
+	v, br.err = binary.ReadUvarint(br)
+	if br.err == io.EOF {
+		br.err = io.ErrUnexpectedEOF
+	}
+	return v
+}
+
+// count reads number of following items, absurd numbers of corrupt snapshot are rejected.
+func (br *binaryReader) count() uint64 {
+	v := br.uvarint()
+	if v > 1<<32 {
+		br.err = errors.New("corrupt snapshot")
+		return 0
+	}
+	return v
+}
+
+func (br *binaryReader) string() string {
+	n := br.count()
+	if br.err != nil {
+		return ""
+	}
+	buf := make([]byte, 0, minUint64(n, 1<<16))
+	for uint64(len(buf)) < n {
+		chunk := make([]byte, minUint64(n-uint64(len(buf)), 1<<16))
+		_, br.err = io.ReadFull(br.r, chunk)
+		if br.err == io.EOF {
+			br.err = io.ErrUnexpectedEOF
+		}
+		if br.err != nil {
+			return ""
+		}
+		_, _ = br.crc.Write(chunk)
+		buf = append(buf, chunk...)
+	}
+	return string(buf)
+}
+
+func (br *binaryReader) ref() string {
+	i := br.uvarint()
+	if br.err != nil {
+		return ""
+	}
+	if i >= uint64(len(br.dict)) {
+		br.err = errors.New("corrupt snapshot")
+		return ""
+	}
+	return br.dict[i]
+}
+
+func (br *binaryReader) refs() []string {
+	n := br.count()
+	var refs []string
+	for i := uint64(0); i < n && br.err == nil; i++ {
+		refs = append(refs, br.ref())
+	}
+	return refs
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
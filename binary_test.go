@@ -0,0 +1,85 @@
+package stable
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestSTable_WriteBinaryTo(t *testing.T) {
+	t.Parallel()
+	rows := []map[string]string{
+		{"pk": "0", "name": "Alex", "city": "New-York"},
+		{"pk": "1", "name": "John", "city": "New-York"},
+		{"pk": "2", "name": "", "city": "London", "position": "JSON Senior Developer"},
+	}
+	s, err := NewSTable(rows, "pk", []string{"city"}, []string{"name"}, WithName("customers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "3", "city": "Paris"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows = append(rows, map[string]string{"pk": "3", "city": "Paris"})
+	for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+		var buf bytes.Buffer
+		n, err := s.WriteBinaryTo(&buf, compression)
+		equal(t, nil, err, "write error")
+		equal(t, int64(buf.Len()), n, "written bytes")
+
+		loaded, err := Load(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		equal(t, "customers", loaded.Name(), "loaded name")
+		equal(t, uint64(1), loaded.LastSeq(), "loaded seq")
+		equal(t, rows, loaded.Find(nil), "loaded rows")
+		_, err = loaded.Insert([]map[string]string{{"pk": "4", "name": "Alex", "city": "Paris"}})
+		equal(t, errors.New("duplicate value \"Alex\" for field \"name\""), err, "loaded uniq fields")
+		_, err = loaded.Insert([]map[string]string{{"pk": "4"}})
+		equal(t, errors.New("empty value for field \"city\""), err, "loaded non empty fields")
+	}
+	_, err = s.WriteBinaryTo(io.Discard, Compression(9))
+	equal(t, errors.New("unsupported compression 9"), err, "unsupported compression")
+}
+
+func TestLoad_BinaryErrors(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "name": "Alex"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, compression := range []Compression{CompressionNone, CompressionGzip} {
+		var buf bytes.Buffer
+		_, err = s.WriteBinaryTo(&buf, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+
+		corrupt := append([]byte{}, data...)
+		corrupt[len(corrupt)-1] ^= 0xff
+		_, err = Load(bytes.NewReader(corrupt))
+		equal(t, ErrSnapshotChecksum, err, "checksum")
+
+		_, err = Load(bytes.NewReader(data[:len(data)-5]))
+		equal(t, true, err != nil, "truncated")
+
+		version := append([]byte{}, data...)
+		version[len(binaryMagic)] = 2
+		_, err = Load(bytes.NewReader(version))
+		equal(t, errors.New("unsupported snapshot version 2"), err, "version")
+	}
+	var buf bytes.Buffer
+	_, err = s.WriteBinaryTo(&buf, CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// body starts with dictionary size followed by the first string length and bytes
+	data[len(binaryMagic)+5] ^= 0xff
+	_, err = Load(bytes.NewReader(data))
+	equal(t, ErrSnapshotChecksum, err, "corrupt body")
+}
@@ -0,0 +1,39 @@
+package stable
+
+import (
+	"errors"
+)
+
+// ErrChangeLogTruncated is returned by STable.ChangesSince when requested changes
+// are no longer in the change log, consumer should reload the whole STable.
+var ErrChangeLogTruncated = errors.New("change log is truncated, resync is required")
+
+func (st *stable) LastSeq() uint64 {
+	st.RLock()
+	defer st.RUnlock()
+	return st.seq
+}
+
+func (st *stable) ChangesSince(seq uint64) ([]Change, error) {
+	st.RLock()
+	defer st.RUnlock()
+	if seq >= st.seq {
+		return []Change{}, nil
+	}
+	if len(st.changeLog) == 0 || st.changeLog[0].Seq > seq+1 {
+		return nil, ErrChangeLogTruncated
+	}
+	first := int(seq + 1 - st.changeLog[0].Seq)
+	return copyChanges(st.changeLog[first:]), nil
+}
+
+// logChanges appends committed changes to the change log keeping its size.
+func (st *stable) logChanges(changes []Change) {
+	if st.changeLogSize <= 0 {
+		return
+	}
+	st.changeLog = append(st.changeLog, copyChanges(changes)...)
+	if extra := len(st.changeLog) - st.changeLogSize; extra > 0 {
+		st.changeLog = append([]Change(nil), st.changeLog[extra:]...)
+	}
+}
@@ -0,0 +1,105 @@
+package stable
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSTable_ChangesSince(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0"}}, "pk", nil, nil, WithChangeLog(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, uint64(0), s.LastSeq(), "initial rows are not changes")
+	_, err = s.Insert([]map[string]string{{"pk": "1"}, {"pk": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Delete(map[string]string{"pk": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, uint64(3), s.LastSeq(), "last seq")
+	type testTableData struct {
+		testCase        string
+		seq             uint64
+		expectedChanges []Change
+		expectedErr     error
+	}
+	testTable := []testTableData{
+		{
+			testCase:        "truncated",
+			seq:             0,
+			expectedChanges: nil,
+			expectedErr:     ErrChangeLogTruncated,
+		},
+		{
+			testCase: "all logged changes",
+			seq:      1,
+			expectedChanges: []Change{
+				{Operation: OperationInsert, New: map[string]string{"pk": "2"}, ChangedFields: []string{"pk"}, Seq: 2},
+				{Operation: OperationDelete, Old: map[string]string{"pk": "0"}, ChangedFields: []string{"pk"}, Seq: 3},
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase: "last change",
+			seq:      2,
+			expectedChanges: []Change{
+				{Operation: OperationDelete, Old: map[string]string{"pk": "0"}, ChangedFields: []string{"pk"}, Seq: 3},
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase:        "up to date",
+			seq:             3,
+			expectedChanges: []Change{},
+			expectedErr:     nil,
+		},
+	}
+	for _, testUnit := range testTable {
+		changes, err := s.ChangesSince(testUnit.seq)
+		equal(t, testUnit.expectedChanges, changes, testUnit.testCase)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+	}
+}
+
+func TestSTable_ChangesSinceWithoutLog(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ChangesSince(0)
+	equal(t, ErrChangeLogTruncated, err, "log disabled")
+}
+
+func TestSTable_ChangesAreCopied(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil, WithChangeLog(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := s.Subscribe(context.Background(), nil, 1, OverflowDrop)
+	trigger := &testAfterTrigger{}
+	s.AddAfterTrigger(trigger)
+	_, err = s.Insert([]map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger.changes[0][0].New["pk"] = "changed"
+	trigger.changes[0][0].ChangedFields[0] = "changed"
+	expected := Change{Operation: OperationInsert, New: map[string]string{"pk": "0"}, ChangedFields: []string{"pk"}, Seq: 1}
+	changes, err := s.ChangesSince(0)
+	equal(t, nil, err, "changes since error")
+	equal(t, []Change{expected}, changes, "logged changes")
+	changes[0].New["pk"] = "changed"
+	changes, _ = s.ChangesSince(0)
+	equal(t, []Change{expected}, changes, "returned changes")
+	equal(t, expected, <-sub.C, "delivered change")
+}
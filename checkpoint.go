@@ -0,0 +1,122 @@
+package stable
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+func (st *stable) Checkpoint() error {
+	if st.snapshotPath == "" {
+		return errors.New("checkpoint is not configured")
+	}
+	st.checkpointMu.Lock()
+	defer st.checkpointMu.Unlock()
+	s := st.snapshot()
+	err := writeFileAtomic(st.snapshotPath, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(s)
+	})
+	if err != nil {
+		return err
+	}
+	if st.wal == nil {
+		return nil
+	}
+	return st.wal.compact(s.Seq)
+}
+
+func (st *stable) CheckpointErr() error {
+	st.checkpointErrMu.Lock()
+	defer st.checkpointErrMu.Unlock()
+	return st.checkpointErr
+}
+
+func (st *stable) Close() error {
+	st.closeOnce.Do(func() {
+		close(st.stop)
+		st.background.Wait()
+		if st.snapshotPath != "" {
+			st.closeErr = st.Checkpoint()
+		}
+		if st.wal != nil {
+			err := st.wal.Close()
+			if st.closeErr == nil {
+				st.closeErr = err
+			}
+		}
+	})
+	return st.closeErr
+}
+
+// startBackground starts background checkpoints when they are enabled.
+func (st *stable) startBackground() {
+	if st.snapshotPath == "" || (st.checkpointInterval <= 0 && st.checkpointWALSize <= 0) {
+		return
+	}
+	st.checkpointRequests = make(chan struct{}, 1)
+	st.background.Add(1)
+	go func() {
+		defer st.background.Done()
+		var tick <-chan time.Time
+		if st.checkpointInterval > 0 {
+			ticker := time.NewTicker(st.checkpointInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case <-st.stop:
+				return
+			case <-tick:
+			case <-st.checkpointRequests:
+			}
+			err := st.Checkpoint()
+			st.checkpointErrMu.Lock()
+			st.checkpointErr = err
+			st.checkpointErrMu.Unlock()
+		}
+	}()
+}
+
+// requestCheckpoint asks for background checkpoint when the write-ahead log is too big.
+func (st *stable) requestCheckpoint() {
+	if st.checkpointRequests == nil || st.wal == nil || st.checkpointWALSize <= 0 ||
+		st.wal.Size() < st.checkpointWALSize {
+		return
+	}
+	select {
+	case st.checkpointRequests <- struct{}{}:
+	default:
+	}
+}
+
+// writeFileAtomic writes file at path by write so that the file is either fully replaced or not changed.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(file)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package stable
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSTable_Checkpoint(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "snapshot")
+	walPath := filepath.Join(dir, "wal")
+	wal, err := OpenWAL(walPath, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSTable(nil, "pk", nil, nil, WithWAL(wal), WithCheckpoint(snapshotPath, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0"}, {"pk": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, nil, s.Checkpoint(), "checkpoint")
+	equal(t, int64(0), wal.Size(), "log is compacted")
+	_, err = s.Delete(map[string]string{"pk": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := wal.Size()
+
+	// simulate crash: recover from the snapshot and the log without Close
+	recovered := recoverTable(t, snapshotPath, walPath)
+	equal(t, []map[string]string{{"pk": "1"}}, recovered.Find(nil), "recovered after crash")
+	equal(t, size, recovered.(*stable).wal.Size(), "log is not compacted before checkpoint")
+	equal(t, nil, recovered.Close(), "close recovered")
+
+	_, err = s.Insert([]map[string]string{{"pk": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, nil, s.Close(), "close")
+	equal(t, nil, s.Close(), "second close")
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, int64(0), info.Size(), "log is compacted by close")
+	recovered = recoverTable(t, snapshotPath, walPath)
+	equal(t, []map[string]string{{"pk": "1"}, {"pk": "2"}}, recovered.Find(nil), "recovered after close")
+	equal(t, uint64(4), recovered.LastSeq(), "recovered seq")
+	equal(t, nil, recovered.Close(), "close recovered")
+}
+
+func TestSTable_BackgroundCheckpoint(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase string
+		interval time.Duration
+		walSize  int64
+	}
+	testTable := []testTableData{
+		{testCase: "by interval", interval: time.Millisecond, walSize: 0},
+		{testCase: "by log size", interval: 0, walSize: 1},
+	}
+	for _, testUnit := range testTable {
+		dir := t.TempDir()
+		snapshotPath := filepath.Join(dir, "snapshot")
+		wal, err := OpenWAL(filepath.Join(dir, "wal"), SyncNever)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewSTable(nil, "pk", nil, nil, WithWAL(wal), WithCheckpoint(snapshotPath, testUnit.interval, testUnit.walSize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Insert([]map[string]string{{"pk": "0"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for wal.Size() != 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		equal(t, int64(0), wal.Size(), testUnit.testCase)
+		equal(t, nil, s.Close(), testUnit.testCase)
+	}
+}
+
+func TestSTable_BackgroundCheckpointErr(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "snapshot")
+	wal, err := OpenWAL(filepath.Join(dir, "wal"), SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSTable(nil, "pk", nil, nil, WithWAL(wal), WithCheckpoint(snapshotPath, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, nil, s.CheckpointErr(), "before checkpoint")
+	// snapshot can not replace directory
+	err = os.Mkdir(snapshotPath, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for s.CheckpointErr() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	equal(t, true, s.CheckpointErr() != nil, "failed checkpoint")
+	equal(t, true, wal.Size() > 0, "log is not compacted")
+
+	err = os.Remove(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(time.Second)
+	for s.CheckpointErr() != nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	equal(t, nil, s.CheckpointErr(), "recovered checkpoint")
+	equal(t, nil, s.Close(), "close")
+}
+
+func TestSTable_CheckpointNotConfigured(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, errors.New("checkpoint is not configured"), s.Checkpoint(), "checkpoint")
+	equal(t, nil, s.Close(), "close")
+}
+
+func recoverTable(t *testing.T, snapshotPath, walPath string) STable {
+	file, err := os.Open(snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	wal, err := OpenWAL(walPath, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(file, WithWAL(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,326 @@
+package stable
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Condition selects rows, see ParseCondition.
+type Condition interface {
+	// Match reports whether row matches the condition.
+	Match(row map[string]string) bool
+}
+
+// ParseCondition parses condition from query like
+//
+//	status = "active" AND (region IN ("eu", "us") OR priority > 5)
+//
+// Comparison operators are =, != (or <>), <, <=, > and >=, field may be tested with IN and NOT IN against a list.
+// Comparisons are combined with AND, OR and NOT, AND binds tighter than OR, parentheses group them.
+// Keywords are case insensitive. Values are string literals in single or double quotes or numbers,
+// fields may be quoted with backticks. Quote is escaped by doubling it.
+//
+// =, != and IN compare values as strings, the others compare values as numbers when both are numbers
+// and as strings otherwise. Comparison of absent field is always false.
+//
+// Returned condition formats itself back to a query with String. *SyntaxError is returned for invalid query.
+func ParseCondition(query string) (Condition, error) {
+	p, err := newParser(query)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := parseOr(p)
+	if err != nil {
+		return nil, err
+	}
+	err = p.expectEOF()
+	if err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+func parseOr(p *parser) (Condition, error) {
+	cond, err := parseAnd(p)
+	if err != nil {
+		return nil, err
+	}
+	conds := orCondition{cond}
+	for p.acceptKeyword("OR") {
+		cond, err = parseAnd(p)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 1 {
+		return cond, nil
+	}
+	return conds, nil
+}
+
+func parseAnd(p *parser) (Condition, error) {
+	cond, err := parseNot(p)
+	if err != nil {
+		return nil, err
+	}
+	conds := andCondition{cond}
+	for p.acceptKeyword("AND") {
+		cond, err = parseNot(p)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 1 {
+		return cond, nil
+	}
+	return conds, nil
+}
+
+func parseNot(p *parser) (Condition, error) {
+	if p.acceptKeyword("NOT") {
+		cond, err := parseNot(p)
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{cond}, nil
+	}
+	if p.accept(tokenLParen) {
+		cond, err := parseOr(p)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(tokenRParen, `")"`)
+		if err != nil {
+			return nil, err
+		}
+		return cond, nil
+	}
+	return parseComparison(p)
+}
+
+func parseComparison(p *parser) (Condition, error) {
+	field, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if p.isKeyword("NOT") || p.isKeyword("IN") {
+		not := p.acceptKeyword("NOT")
+		err = p.expectKeyword("IN")
+		if err != nil {
+			return nil, err
+		}
+		values, err := parseValueList(p)
+		if err != nil {
+			return nil, err
+		}
+		return inCondition{field: field, values: values, not: not}, nil
+	}
+	op, err := p.expect(tokenOperator, "operator")
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseConditionValue(p)
+	if err != nil {
+		return nil, err
+	}
+	if op.text == "<>" {
+		op.text = "!="
+	}
+	return compareCondition{field: field, op: op.text, value: value}, nil
+}
+
+func parseValueList(p *parser) ([]string, error) {
+	_, err := p.expect(tokenLParen, `"("`)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for {
+		value, err := parseConditionValue(p)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.accept(tokenComma) {
+			break
+		}
+	}
+	_, err = p.expect(tokenRParen, `")"`)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseConditionValue(p *parser) (string, error) {
+	t := p.peek()
+	if t.kind != tokenString && t.kind != tokenNumber {
+		return "", p.unexpected("value")
+	}
+	p.advance()
+	return t.text, nil
+}
+
+type andCondition []Condition
+
+func (c andCondition) Match(row map[string]string) bool {
+	for _, cond := range c {
+		if !cond.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c andCondition) String() string {
+	return joinConditions(c, " AND ", func(cond Condition) bool {
+		_, ok := cond.(orCondition)
+		return ok
+	})
+}
+
+type orCondition []Condition
+
+func (c orCondition) Match(row map[string]string) bool {
+	for _, cond := range c {
+		if cond.Match(row) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c orCondition) String() string {
+	return joinConditions(c, " OR ", func(Condition) bool { return false })
+}
+
+// joinConditions formats conditions joined by sep, conditions are parenthesized when needParens.
+func joinConditions(conds []Condition, sep string, needParens func(Condition) bool) string {
+	parts := make([]string, len(conds))
+	for i, cond := range conds {
+		parts[i] = formatCondition(cond)
+		if needParens(cond) {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+type notCondition struct {
+	cond Condition
+}
+
+func (c notCondition) Match(row map[string]string) bool {
+	return !c.cond.Match(row)
+}
+
+func (c notCondition) String() string {
+	switch c.cond.(type) {
+	case andCondition, orCondition:
+		return "NOT (" + formatCondition(c.cond) + ")"
+	}
+	return "NOT " + formatCondition(c.cond)
+}
+
+type compareCondition struct {
+	field string
+	op    string
+	value string
+}
+
+func (c compareCondition) Match(row map[string]string) bool {
+	value, ok := row[c.field]
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "=":
+		return value == c.value
+	case "!=":
+		return value != c.value
+	case "<":
+		return compareValues(value, c.value) < 0
+	case "<=":
+		return compareValues(value, c.value) <= 0
+	case ">":
+		return compareValues(value, c.value) > 0
+	case ">=":
+		return compareValues(value, c.value) >= 0
+	}
+	return false
+}
+
+func (c compareCondition) String() string {
+	return quoteIdent(c.field) + " " + c.op + " " + quoteValue(c.value)
+}
+
+type inCondition struct {
+	field  string
+	values []string
+	not    bool
+}
+
+func (c inCondition) Match(row map[string]string) bool {
+	value, ok := row[c.field]
+	if !ok {
+		return false
+	}
+	for _, v := range c.values {
+		if value == v {
+			return !c.not
+		}
+	}
+	return c.not
+}
+
+func (c inCondition) String() string {
+	values := make([]string, len(c.values))
+	for i, value := range c.values {
+		values[i] = quoteValue(value)
+	}
+	op := " IN ("
+	if c.not {
+		op = " NOT IN ("
+	}
+	return quoteIdent(c.field) + op + strings.Join(values, ", ") + ")"
+}
+
+// whereCondition matches rows by equality of fields like map conditions of STable methods.
+type whereCondition map[string]string
+
+func (c whereCondition) Match(row map[string]string) bool {
+	return matches(row, c)
+}
+
+func formatCondition(cond Condition) string {
+	if s, ok := cond.(interface{ String() string }); ok {
+		return s.String()
+	}
+	return "<condition>"
+}
+
+func quoteValue(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+}
+
+// quoteIdent quotes field with backticks unless it is a plain identifier.
+func quoteIdent(field string) string {
+	plain := field != "" && !isDigit(field[0])
+	for _, r := range field {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) || r == utf8.RuneError {
+			plain = false
+		}
+	}
+	for _, keyword := range []string{"AND", "OR", "NOT", "IN"} {
+		if strings.EqualFold(field, keyword) {
+			plain = false
+		}
+	}
+	if plain {
+		return field
+	}
+	return "`" + strings.ReplaceAll(field, "`", "``") + "`"
+}
@@ -0,0 +1,159 @@
+package stable
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	t.Parallel()
+	rows := []map[string]string{
+		{"id": "1", "status": "active", "region": "eu", "priority": "3"},
+		{"id": "2", "status": "active", "region": "asia", "priority": "10"},
+		{"id": "3", "status": "blocked", "region": "us", "priority": "7"},
+		{"id": "4", "status": "active", "region": "us"},
+		{"id": "5", "status": "it's \"new\"", "my field": "x"},
+	}
+	type testTableData struct {
+		testCase       string
+		query          string
+		expectedString string
+		expectedIDs    []string
+		expectedErr    error
+	}
+	testTable := []testTableData{
+		{
+			testCase:       "example",
+			query:          `status = "active" AND (region IN ("eu","us") OR priority > 5)`,
+			expectedString: `status = "active" AND (region IN ("eu", "us") OR priority > "5")`,
+			expectedIDs:    []string{"1", "2", "4"},
+			expectedErr:    nil,
+		},
+		{
+			testCase:       "AND binds tighter than OR",
+			query:          `region = 'us' or region = 'eu' and priority >= 3`,
+			expectedString: `region = "us" OR region = "eu" AND priority >= "3"`,
+			expectedIDs:    []string{"1", "3", "4"},
+			expectedErr:    nil,
+		},
+		{
+			testCase:       "numeric comparison",
+			query:          `priority < 10 AND priority <= 7.0`,
+			expectedString: `priority < "10" AND priority <= "7.0"`,
+			expectedIDs:    []string{"1", "3"},
+			expectedErr:    nil,
+		},
+		{
+			testCase:       "absent field",
+			query:          `priority != 3`,
+			expectedString: `priority != "3"`,
+			expectedIDs:    []string{"2", "3"},
+			expectedErr:    nil,
+		},
+		{
+			testCase:       "NOT and NOT IN",
+			query:          `NOT (status = "active") OR region NOT IN ('eu', 'asia', 'us')`,
+			expectedString: `NOT status = "active" OR region NOT IN ("eu", "asia", "us")`,
+			expectedIDs:    []string{"3", "5"},
+			expectedErr:    nil,
+		},
+		{
+			testCase:       "quoted field and value",
+			query:          "`my field` <> 'y' AND status = 'it''s \"new\"'",
+			expectedString: "`my field` != \"y\" AND status = \"it's \"\"new\"\"\"",
+			expectedIDs:    []string{"5"},
+			expectedErr:    nil,
+		},
+		{
+			testCase:       "missing value",
+			query:          `status = AND region = "eu"`,
+			expectedString: "",
+			expectedIDs:    nil,
+			expectedErr:    &SyntaxError{Pos: 9, Msg: `expected value, found "AND"`},
+		},
+		{
+			testCase:       "missing parenthesis",
+			query:          `(status = "active"`,
+			expectedString: "",
+			expectedIDs:    nil,
+			expectedErr:    &SyntaxError{Pos: 18, Msg: `expected ")", found end of query`},
+		},
+		{
+			testCase:       "missing operator",
+			query:          `status "active"`,
+			expectedString: "",
+			expectedIDs:    nil,
+			expectedErr:    &SyntaxError{Pos: 7, Msg: `expected operator, found string "active"`},
+		},
+		{
+			testCase:       "empty IN list",
+			query:          `region IN ()`,
+			expectedString: "",
+			expectedIDs:    nil,
+			expectedErr:    &SyntaxError{Pos: 11, Msg: `expected value, found ")"`},
+		},
+		{
+			testCase:       "trailing tokens",
+			query:          `region = "eu" region = "us"`,
+			expectedString: "",
+			expectedIDs:    nil,
+			expectedErr:    &SyntaxError{Pos: 14, Msg: `expected end of query, found "region"`},
+		},
+		{
+			testCase:       "lexer error",
+			query:          `region = "eu`,
+			expectedString: "",
+			expectedIDs:    nil,
+			expectedErr:    &SyntaxError{Pos: 9, Msg: "unterminated quoted string"},
+		},
+	}
+	for _, testUnit := range testTable {
+		cond, err := ParseCondition(testUnit.query)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		if err != nil {
+			continue
+		}
+		var ids []string
+		for _, row := range rows {
+			if cond.Match(row) {
+				ids = append(ids, row["id"])
+			}
+		}
+		equal(t, testUnit.expectedIDs, ids, testUnit.testCase)
+		equal(t, testUnit.expectedString, fmt.Sprint(cond), testUnit.testCase)
+	}
+}
+
+func FuzzParseCondition(f *testing.F) {
+	for _, seed := range []string{
+		`status = "active" AND (region IN ("eu","us") OR priority > 5)`,
+		`NOT (a != 'x' OR b <> -1.5) AND c NOT IN (1, "2")`,
+		"`and` <= 'it''s'",
+		`a = "`,
+		`((a = 1)`,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, query string) {
+		cond, err := ParseCondition(query)
+		if err != nil {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("error %v is not a syntax error", err)
+			}
+			if syntaxErr.Pos < 0 || syntaxErr.Pos > len(query) {
+				t.Fatalf("position %v of error %v is out of query %q", syntaxErr.Pos, err, query)
+			}
+			return
+		}
+		formatted := fmt.Sprint(cond)
+		reparsed, err := ParseCondition(formatted)
+		if err != nil {
+			t.Fatalf("formatted condition %q of query %q is not parsed: %v", formatted, query, err)
+		}
+		if fmt.Sprint(reparsed) != formatted {
+			t.Fatalf("formatted condition %q of query %q is formatted as %q", formatted, query, fmt.Sprint(reparsed))
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package stable
+
+import (
+	"context"
+)
+
+// lockContext locks STable for writing or returns ctx error when ctx is done first.
+func (st *stable) lockContext(ctx context.Context) error {
+	return lockContext(ctx, st.TryLock, st.Lock, st.Unlock)
+}
+
+// rLockContext locks STable for reading or returns ctx error when ctx is done first.
+func (st *stable) rLockContext(ctx context.Context) error {
+	return lockContext(ctx, st.TryRLock, st.RLock, st.RUnlock)
+}
+
+// lockContext waits for contended lock in goroutine, so free lock is taken without it.
+func lockContext(ctx context.Context, tryLock func() bool, lock, unlock func()) error {
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tryLock() {
+		return nil
+	}
+	locked := make(chan struct{})
+	go func() {
+		lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		// release the lock when it is finally acquired
+		go func() {
+			<-locked
+			unlock()
+		}()
+		return ctx.Err()
+	}
+}
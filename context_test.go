@@ -0,0 +1,117 @@
+package stable
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSTable_ContextLock(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	s.AddTrigger(TriggerFunc(func(operation int, new, old map[string]string) error {
+		if new["pk"] == "blocking" {
+			close(locked)
+			<-release
+		}
+		return nil
+	}))
+	inserted := make(chan error)
+	go func() {
+		_, err := s.Insert([]map[string]string{{"pk": "blocking"}})
+		inserted <- err
+	}()
+	<-locked
+
+	type testTableData struct {
+		testCase string
+		run      func(ctx context.Context) error
+	}
+	testTable := []testTableData{
+		{testCase: "insert", run: func(ctx context.Context) error {
+			_, err := s.InsertContext(ctx, []map[string]string{{"pk": "1"}})
+			return err
+		}},
+		{testCase: "upsert", run: func(ctx context.Context) error {
+			_, err := s.UpsertContext(ctx, []map[string]string{{"pk": "1"}})
+			return err
+		}},
+		{testCase: "update", run: func(ctx context.Context) error {
+			_, err := s.UpdateContext(ctx, map[string]string{"f1": "v1"}, nil)
+			return err
+		}},
+		{testCase: "delete", run: func(ctx context.Context) error {
+			_, err := s.DeleteContext(ctx, nil)
+			return err
+		}},
+		{testCase: "select", run: func(ctx context.Context) error {
+			_, err := s.SelectContext(ctx, nil)
+			return err
+		}},
+	}
+	for _, testUnit := range testTable {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		equal(t, context.DeadlineExceeded, testUnit.run(ctx), testUnit.testCase)
+		cancel()
+	}
+
+	close(release)
+	equal(t, nil, <-inserted, "blocking insert")
+	equal(t, []map[string]string{{"pk": "0"}, {"pk": "blocking"}}, s.Find(nil), "abandoned locks are released")
+}
+
+func TestLockContext(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase      string
+		free          bool
+		expectedLocks int
+	}
+	testTable := []testTableData{
+		{testCase: "free lock is taken without waiting", free: true, expectedLocks: 0},
+		{testCase: "contended lock is waited for", free: false, expectedLocks: 1},
+	}
+	for _, testUnit := range testTable {
+		ctx, cancel := context.WithCancel(context.Background())
+		locks := 0
+		err := lockContext(ctx, func() bool { return testUnit.free }, func() { locks++ }, func() {})
+		cancel()
+		equal(t, nil, err, testUnit.testCase)
+		equal(t, testUnit.expectedLocks, locks, testUnit.testCase)
+	}
+}
+
+func TestSTable_ContextTrigger(t *testing.T) {
+	t.Parallel()
+	type ctxKey struct{}
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []interface{}
+	s.AddContextTrigger(ContextTriggerFunc(func(ctx context.Context, change Change) error {
+		values = append(values, ctx.Value(ctxKey{}))
+		return nil
+	}))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	_, err = s.InsertContext(ctx, []map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Delete(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []interface{}{"value", nil}, values, "ctx passed to trigger")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.InsertContext(cancelled, []map[string]string{{"pk": "1"}})
+	equal(t, context.Canceled, err, "cancelled ctx")
+	equal(t, []map[string]string{}, s.Find(nil), "nothing inserted with cancelled ctx")
+}
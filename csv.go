@@ -0,0 +1,179 @@
+package stable
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CSVOptions configures ImportCSV.
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' is used when it is zero.
+	Comma rune
+	// EmptyAsAbsent skips fields with empty cells instead of storing empty values.
+	EmptyAsAbsent bool
+	// Upsert imports rows by STable.Upsert instead of STable.Insert.
+	Upsert bool
+}
+
+// CSVError represents ImportCSV error at the line of input.
+type CSVError struct {
+	Line int
+	Err  error
+}
+
+func (e *CSVError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CSVError) Unwrap() error {
+	return e.Err
+}
+
+// ImportCSV reads CSV from r and writes its rows to STable with one Insert or Upsert.
+// The header line gives field names.
+// Errors of the input and constraints errors of rows are reported as *CSVError with the line number.
+// Other errors, like errors of triggers, are returned as they are returned by STable.
+func ImportCSV(st STable, r io.Reader, opts CSVOptions) (int, error) {
+	rows, lines, err := readCSV(r, opts)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	table, ok := st.(*stable)
+	if !ok {
+		if opts.Upsert {
+			return st.Upsert(rows)
+		}
+		return st.Insert(rows)
+	}
+	affected, err := table.importRows(rows, opts.Upsert)
+	if ce, ok := err.(*constraintError); ok && ce.row >= 0 {
+		return affected, &CSVError{Line: lines[ce.row], Err: ce.err}
+	}
+	return affected, constraintCause(err)
+}
+
+// importRows writes rows like Insert or Upsert, constraints errors are returned as *constraintError.
+func (st *stable) importRows(rows []map[string]string, upsert bool) (int, error) {
+	st.Lock()
+	defer st.Unlock()
+	if upsert {
+		return st.upsertRows(context.Background(), rows)
+	}
+	return st.insertRows(context.Background(), rows)
+}
+
+// readCSV returns rows of CSV with their line numbers.
+func readCSV(r io.Reader, opts CSVOptions) ([]map[string]string, []int, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, csvError(err, 1)
+	}
+	err = checkCSVHeader(header)
+	if err != nil {
+		line, _ := cr.FieldPos(0)
+		return nil, nil, &CSVError{Line: line, Err: err}
+	}
+	rows := make([]map[string]string, 0)
+	lines := make([]int, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, lines, nil
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return nil, nil, csvError(err, line)
+		}
+		row := make(map[string]string, len(record))
+		for i, value := range record {
+			if value == "" && opts.EmptyAsAbsent {
+				continue
+			}
+			row[header[i]] = value
+		}
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+}
+
+func checkCSVHeader(header []string) error {
+	seen := make(map[string]struct{}, len(header))
+	for _, field := range header {
+		if field == "" {
+			return errors.New("empty field name in header")
+		}
+		if _, ok := seen[field]; ok {
+			return fmt.Errorf("duplicate field name \"%v\" in header", field)
+		}
+		seen[field] = struct{}{}
+	}
+	return nil
+}
+
+// csvError converts error of csv.Reader to *CSVError.
+func csvError(err error, line int) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &CSVError{Line: parseErr.Line, Err: parseErr.Err}
+	}
+	return &CSVError{Line: line, Err: err}
+}
+
+// ExportCSV writes rows selected by conditions to w as CSV with the header line.
+// Columns are written in order of fields, when fields are empty all fields of selected rows are
+// written in sorted order. Absent fields are written as empty cells.
+func ExportCSV(st STable, w io.Writer, where map[string]string, fields []string) error {
+	rows := st.Find(where)
+	if len(fields) == 0 {
+		fields = rowsFields(rows)
+	}
+	cw := csv.NewWriter(w)
+	err := cw.Write(fields)
+	if err != nil {
+		return err
+	}
+	record := make([]string, len(fields))
+	for _, row := range rows {
+		for i, field := range fields {
+			record[i] = row[field]
+		}
+		err = cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// rowsFields returns sorted names of all fields of rows.
+func rowsFields(rows []map[string]string) []string {
+	seen := make(map[string]struct{})
+	fields := make([]string, 0)
+	for _, row := range rows {
+		for field := range row {
+			if _, ok := seen[field]; ok {
+				continue
+			}
+			seen[field] = struct{}{}
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
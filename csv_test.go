@@ -0,0 +1,178 @@
+package stable
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase         string
+		data             string
+		opts             CSVOptions
+		expectedAffected int
+		expectedErr      error
+		expectedSelected []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase:         "insert",
+			data:             "pk,name,city\n1,Alex,\n2,John,London\n",
+			opts:             CSVOptions{},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "name": "Bill"},
+				{"pk": "1", "name": "Alex", "city": ""},
+				{"pk": "2", "name": "John", "city": "London"},
+			},
+		},
+		{
+			testCase:         "upsert with delimiter and empty as absent",
+			data:             "pk;city\n0;Paris\n1;\n",
+			opts:             CSVOptions{Comma: ';', EmptyAsAbsent: true, Upsert: true},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "name": "Bill", "city": "Paris"},
+				{"pk": "1"},
+			},
+		},
+		{
+			testCase:         "empty input",
+			data:             "",
+			opts:             CSVOptions{},
+			expectedAffected: 0,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "wrong number of fields",
+			data:             "pk,name\n1,Alex\n2\n",
+			opts:             CSVOptions{},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 3, Err: errors.New("wrong number of fields")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "duplicate field in header",
+			data:             "pk,name,name\n1,Alex,Alex\n",
+			opts:             CSVOptions{},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 1, Err: errors.New("duplicate field name \"name\" in header")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "constraints",
+			data:             "pk,name\n0,Alex\n",
+			opts:             CSVOptions{},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 2, Err: errors.New("duplicate value \"0\" for field \"pk\"")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "unique field",
+			data:             "pk,name\n1,Alex\n2,Bill\n",
+			opts:             CSVOptions{},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 3, Err: errors.New("duplicate value \"Bill\" for field \"name\"")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "empty primary key",
+			data:             "pk,name\n1,Alex\n\n,John\n",
+			opts:             CSVOptions{EmptyAsAbsent: true},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 4, Err: errors.New("empty value for field \"pk\"")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "upsert of the same primary key",
+			data:             "pk,name\n1,Alex\n1,John\n",
+			opts:             CSVOptions{Upsert: true},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 3, Err: errors.New("duplicate value \"1\" for field \"pk\"")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+		{
+			testCase:         "upsert unique field",
+			data:             "pk,name\n0,Alex\n1,John\n2,Alex\n",
+			opts:             CSVOptions{Upsert: true},
+			expectedAffected: 0,
+			expectedErr:      &CSVError{Line: 4, Err: errors.New("duplicate value \"Alex\" for field \"name\"")},
+			expectedSelected: []map[string]string{{"pk": "0", "name": "Bill"}},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable([]map[string]string{{"pk": "0", "name": "Bill"}}, "pk", nil, []string{"name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		affected, err := ImportCSV(s, strings.NewReader(testUnit.data), testUnit.opts)
+		equal(t, testUnit.expectedAffected, affected, testUnit.testCase)
+		if testUnit.expectedErr == nil || err == nil {
+			equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		} else {
+			equal(t, testUnit.expectedErr.Error(), err.Error(), testUnit.testCase)
+		}
+		equal(t, testUnit.expectedSelected, s.Find(nil), testUnit.testCase)
+	}
+}
+
+func TestImportCSVBeforeTrigger(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "name": "Bill"}}, "pk", nil, []string{"name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddBeforeTrigger(testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+		if new["pk"] == "2" {
+			new["name"] = "Bill"
+		}
+		return new, nil
+	}))
+	affected, err := ImportCSV(s, strings.NewReader("pk,name\n1,Alex\n2,John\n"), CSVOptions{})
+	equal(t, 0, affected, "affected")
+	equal(t, &CSVError{Line: 3, Err: errors.New("duplicate value \"Bill\" for field \"name\"")}, err, "row changed by trigger")
+}
+
+func TestExportCSV(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "name": "Alex", "city": "New-York"},
+		{"pk": "1", "name": "John, Jr.", "city": "London"},
+		{"pk": "2", "name": "Bill", "position": "Developer"},
+	}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type testTableData struct {
+		testCase     string
+		where        map[string]string
+		fields       []string
+		expectedData string
+	}
+	testTable := []testTableData{
+		{
+			testCase:     "all fields",
+			where:        nil,
+			fields:       nil,
+			expectedData: "city,name,pk,position\nNew-York,Alex,0,\nLondon,\"John, Jr.\",1,\n,Bill,2,Developer\n",
+		},
+		{
+			testCase:     "selected fields",
+			where:        map[string]string{"pk": "1"},
+			fields:       []string{"pk", "name"},
+			expectedData: "pk,name\n1,\"John, Jr.\"\n",
+		},
+	}
+	for _, testUnit := range testTable {
+		var buf bytes.Buffer
+		err := ExportCSV(s, &buf, testUnit.where, testUnit.fields)
+		equal(t, nil, err, testUnit.testCase)
+		equal(t, testUnit.expectedData, buf.String(), testUnit.testCase)
+	}
+}
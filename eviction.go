@@ -0,0 +1,81 @@
+package stable
+
+import (
+	"fmt"
+	"sort"
+)
+
+// EvictionPolicy defines what happens when write exceeds maximum number of rows, see WithMaxRows.
+type EvictionPolicy int
+
+const (
+	// EvictionReject rejects write with *TableFullError.
+	EvictionReject EvictionPolicy = iota
+	// EvictionLRU deletes least recently read or written rows.
+	EvictionLRU
+	// EvictionLFU deletes least frequently read rows, least recently used of them first.
+	EvictionLFU
+	// EvictionOldest deletes rows inserted first.
+	EvictionOldest
+)
+
+// TableFullError is returned when write exceeds maximum number of rows and rows can not be evicted.
+type TableFullError struct {
+	MaxRows int
+}
+
+func (e *TableFullError) Error() string {
+	return fmt.Sprintf("table is full: maximum is %v rows", e.MaxRows)
+}
+
+// evict deletes rows exceeding maximum number from rows which are going to be committed with written new rows.
+// Written rows are never evicted, deletes of evicted rows have ReasonEvicted.
+// Triggers are called for them, but errors of triggers do not reject them and do not skip following triggers.
+func (st *stable) evict(rows []map[string]string, new []map[string]string) ([]map[string]string, error) {
+	if st.maxRows <= 0 || len(rows) <= st.maxRows {
+		return rows, nil
+	}
+	if st.eviction == EvictionReject {
+		return nil, &TableFullError{MaxRows: st.maxRows}
+	}
+	written := make(map[string]struct{}, len(new))
+	for _, row := range new {
+		written[row[st.primaryKeyField]] = struct{}{}
+	}
+	type candidate struct {
+		pk   string
+		meta *rowMeta
+	}
+	candidates := make([]candidate, 0, len(rows))
+	for _, row := range rows {
+		pk := row[st.primaryKeyField]
+		meta, ok := st.meta[pk]
+		if _, isWritten := written[pk]; ok && !isWritten {
+			candidates = append(candidates, candidate{pk: pk, meta: meta})
+		}
+	}
+	excess := len(rows) - st.maxRows
+	if len(candidates) < excess {
+		return nil, &TableFullError{MaxRows: st.maxRows}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i].meta, candidates[j].meta
+		switch st.eviction {
+		case EvictionLFU:
+			if a.hits.Load() != b.hits.Load() {
+				return a.hits.Load() < b.hits.Load()
+			}
+			return a.accessed.Load() < b.accessed.Load()
+		case EvictionLRU:
+			return a.accessed.Load() < b.accessed.Load()
+		}
+		return a.inserted < b.inserted
+	})
+	evicted := make([]map[string]string, excess)
+	st.deleteReasons = make(map[string]ChangeReason, excess)
+	for i, c := range candidates[:excess] {
+		evicted[i] = map[string]string{st.primaryKeyField: c.pk}
+		st.deleteReasons[c.pk] = ReasonEvicted
+	}
+	return st.deleteRows(rows, evicted), nil
+}
@@ -0,0 +1,171 @@
+package stable
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSTable_MaxRows(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase         string
+		policy           EvictionPolicy
+		reads            []string
+		insert           []map[string]string
+		expectedAffected int
+		expectedErr      error
+		expectedEvicted  []string
+		expectedSelected []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase:         "reject",
+			policy:           EvictionReject,
+			reads:            nil,
+			insert:           []map[string]string{{"pk": "3"}},
+			expectedAffected: 0,
+			expectedErr:      &TableFullError{MaxRows: 3},
+			expectedEvicted:  nil,
+			expectedSelected: []map[string]string{{"pk": "0"}, {"pk": "1"}, {"pk": "2"}},
+		},
+		{
+			testCase:         "LRU",
+			policy:           EvictionLRU,
+			reads:            []string{"1", "0", "2", "1"},
+			insert:           []map[string]string{{"pk": "3"}, {"pk": "4"}},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedEvicted:  []string{"0", "2"},
+			expectedSelected: []map[string]string{{"pk": "1"}, {"pk": "3"}, {"pk": "4"}},
+		},
+		{
+			testCase:         "LFU",
+			policy:           EvictionLFU,
+			reads:            []string{"2", "0", "1", "1"},
+			insert:           []map[string]string{{"pk": "3"}},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedEvicted:  []string{"2"},
+			expectedSelected: []map[string]string{{"pk": "0"}, {"pk": "1"}, {"pk": "3"}},
+		},
+		{
+			testCase:         "oldest",
+			policy:           EvictionOldest,
+			reads:            []string{"1", "0"},
+			insert:           []map[string]string{{"pk": "3"}},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedEvicted:  []string{"0"},
+			expectedSelected: []map[string]string{{"pk": "1"}, {"pk": "2"}, {"pk": "3"}},
+		},
+		{
+			testCase:         "written rows are not evicted",
+			policy:           EvictionOldest,
+			reads:            nil,
+			insert:           []map[string]string{{"pk": "3"}, {"pk": "4"}, {"pk": "5"}, {"pk": "6"}},
+			expectedAffected: 0,
+			expectedErr:      &TableFullError{MaxRows: 3},
+			expectedEvicted:  nil,
+			expectedSelected: []map[string]string{{"pk": "0"}, {"pk": "1"}, {"pk": "2"}},
+		},
+		{
+			testCase:         "nothing evicted on constraints error",
+			policy:           EvictionOldest,
+			reads:            nil,
+			insert:           []map[string]string{{"pk": "3"}, {"pk": "1"}},
+			expectedAffected: 0,
+			expectedErr:      errors.New("duplicate value \"1\" for field \"pk\""),
+			expectedEvicted:  nil,
+			expectedSelected: []map[string]string{{"pk": "0"}, {"pk": "1"}, {"pk": "2"}},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable([]map[string]string{{"pk": "0"}, {"pk": "1"}, {"pk": "2"}}, "pk", nil, nil,
+			WithMaxRows(3, testUnit.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var evicted []string
+		s.AddChangeTrigger(ChangeTriggerFunc(func(change Change) error {
+			if change.Reason == ReasonEvicted {
+				evicted = append(evicted, change.Old["pk"])
+			}
+			return nil
+		}))
+		for _, pk := range testUnit.reads {
+			_, err = s.Select(map[string]string{"pk": pk})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		affected, err := s.Insert(testUnit.insert)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedAffected, affected, testUnit.testCase)
+		equal(t, testUnit.expectedEvicted, evicted, testUnit.testCase)
+		equal(t, testUnit.expectedSelected, s.Find(nil), testUnit.testCase)
+	}
+}
+
+func TestSTable_MaxRowsUpsert(t *testing.T) {
+	t.Parallel()
+	_, err := NewSTable([]map[string]string{{"pk": "0"}, {"pk": "1"}}, "pk", nil, nil, WithMaxRows(1, EvictionLRU))
+	var fullErr *TableFullError
+	equal(t, true, errors.As(err, &fullErr), "initial rows exceed maximum")
+
+	s, err := NewSTable([]map[string]string{{"pk": "0"}, {"pk": "1"}}, "pk", nil, nil, WithMaxRows(2, EvictionLRU))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Get("0")
+	affected, err := s.Upsert([]map[string]string{{"pk": "0", "f1": "v0"}, {"pk": "2"}})
+	equal(t, nil, err, "upsert error")
+	equal(t, 2, affected, "upsert affected")
+	equal(t, []map[string]string{{"pk": "0", "f1": "v0"}, {"pk": "2"}}, s.Find(nil), "upsert evicts not written row")
+	affected, err = s.Update(map[string]string{"f1": "v1"}, nil)
+	equal(t, nil, err, "update error")
+	equal(t, 2, affected, "update does not evict")
+}
+
+func TestSTable_MaxRowsTriggerErrors(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0"}, {"pk": "1"}}, "pk", nil, nil, WithMaxRows(2, EvictionOldest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejectDelete := errors.New("delete is rejected")
+	var beforeDeletes []string
+	for i := 0; i < 2; i++ {
+		s.AddBeforeTrigger(testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+			if operation == OperationDelete {
+				beforeDeletes = append(beforeDeletes, old["pk"])
+				return nil, rejectDelete
+			}
+			return new, nil
+		}))
+	}
+	s.AddTrigger(TriggerFunc(func(operation int, new, old map[string]string) error {
+		if operation == OperationDelete {
+			return rejectDelete
+		}
+		return nil
+	}))
+	var changes []Change
+	s.AddChangeTrigger(ChangeTriggerFunc(func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	}))
+
+	affected, err := s.Insert([]map[string]string{{"pk": "2"}})
+	equal(t, nil, err, "insert error")
+	equal(t, 1, affected, "insert affected")
+	equal(t, []map[string]string{{"pk": "1"}, {"pk": "2"}}, s.Find(nil), "oldest row is evicted")
+	equal(t, []string{"0", "0"}, beforeDeletes, "every BEFORE trigger is called for evicted row")
+	equal(t, []Change{
+		{Operation: OperationInsert, New: map[string]string{"pk": "2"}, ChangedFields: []string{"pk"}, Reason: ReasonWrite},
+		{Operation: OperationDelete, Old: map[string]string{"pk": "0"}, ChangedFields: []string{"pk"}, Reason: ReasonEvicted},
+	}, changes, "trigger after failed one is called for evicted row")
+
+	affected, err = s.Delete(map[string]string{"pk": "1"})
+	equal(t, rejectDelete, err, "delete error")
+	equal(t, 0, affected, "delete affected")
+}
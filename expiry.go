@@ -0,0 +1,101 @@
+package stable
+
+import (
+	"context"
+	"time"
+)
+
+const defaultSweepInterval = time.Minute
+
+func (st *stable) expiryEnabled() bool {
+	return st.ttl > 0 || st.expiryField != ""
+}
+
+func (st *stable) expired(row map[string]string, now time.Time) bool {
+	if st.ttl > 0 {
+		meta, ok := st.meta[row[st.primaryKeyField]]
+		if ok && !now.Before(meta.changed.Add(st.ttl)) {
+			return true
+		}
+	}
+	if st.expiryField != "" {
+		value := row[st.expiryField]
+		if value == "" {
+			return false
+		}
+		expiresAt, err := time.Parse(time.RFC3339Nano, value)
+		if err == nil && !now.Before(expiresAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleRows returns committed rows which are not expired.
+func (st *stable) visibleRows() []map[string]string {
+	if !st.expiryEnabled() {
+		return st.rows
+	}
+	now := st.now()
+	rows := make([]map[string]string, 0, len(st.rows))
+	for _, row := range st.rows {
+		if !st.expired(row, now) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// expire deletes expired rows, their changes have ReasonExpired.
+// Errors of triggers are not reported because expiry is not requested by caller,
+// otherwise a trigger rejecting deletes would make STable unwritable.
+func (st *stable) expire(ctx context.Context) error {
+	if !st.expiryEnabled() {
+		return nil
+	}
+	now := st.now()
+	var expired []map[string]string
+	for _, row := range st.rows {
+		if st.expired(row, now) {
+			expired = append(expired, row)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	rows := st.getRowsCopy()
+	rows = st.deleteRows(rows, expired)
+	st.deleteReasons = make(map[string]ChangeReason, len(expired))
+	for _, row := range expired {
+		st.deleteReasons[row[st.primaryKeyField]] = ReasonExpired
+	}
+	err := st.commit(ctx, rows)
+	if _, ok := err.(*AfterTriggerError); ok {
+		return nil
+	}
+	return err
+}
+
+// startSweeper starts background deletion of expired rows when it is enabled.
+func (st *stable) startSweeper() {
+	if !st.expiryEnabled() || st.sweepInterval <= 0 {
+		return
+	}
+	st.background.Add(1)
+	go func() {
+		defer st.background.Done()
+		ticker := time.NewTicker(st.sweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-st.stop:
+				return
+			case <-ticker.C:
+			}
+			st.Lock()
+			// rows failed to expire are invisible anyway and are retried by the next sweep
+			_ = st.expire(context.Background())
+			st.Unlock()
+		}
+	}()
+}
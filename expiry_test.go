@@ -0,0 +1,272 @@
+package stable
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSTable_TTL(t *testing.T) {
+	t.Parallel()
+	clock := newTestClock()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "f1": "v0"},
+	}, "pk", nil, nil, WithTTL(time.Minute), WithClock(clock.Now), WithSweepInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []Change
+	s.AddChangeTrigger(ChangeTriggerFunc(func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	}))
+
+	clock.Add(30 * time.Second)
+	_, err = s.Insert([]map[string]string{{"pk": "1", "f1": "v1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(30 * time.Second)
+	equal(t, []map[string]string{{"pk": "1", "f1": "v1"}}, s.Find(nil), "find after first row expired")
+	_, ok := s.Get("0")
+	equal(t, false, ok, "get expired row")
+	equal(t, []string{"v1"}, s.Distinct("f1", nil), "distinct")
+	rows := s.Query(nil)
+	var queried []map[string]string
+	for rows.Next() {
+		queried = append(queried, rows.Row())
+	}
+	equal(t, []map[string]string{{"pk": "1", "f1": "v1"}}, queried, "query")
+	equal(t, 1, len(changes), "expired row is not deleted before write")
+
+	affected, err := s.Update(map[string]string{"f1": "v2"}, map[string]string{"pk": "1"})
+	equal(t, nil, err, "update error")
+	equal(t, 1, affected, "update affected")
+	clock.Add(59 * time.Second)
+	_, ok = s.Get("1")
+	equal(t, true, ok, "update postpones expiry")
+
+	affected, err = s.Insert([]map[string]string{{"pk": "0", "f1": "v3"}})
+	equal(t, nil, err, "insert primary key of expired row error")
+	equal(t, 1, affected, "insert primary key of expired row affected")
+	equal(t, []Change{
+		{
+			Operation:     OperationInsert,
+			New:           map[string]string{"pk": "1", "f1": "v1"},
+			ChangedFields: []string{"f1", "pk"},
+			Reason:        ReasonWrite,
+		},
+		{
+			Operation:     OperationDelete,
+			Old:           map[string]string{"pk": "0", "f1": "v0"},
+			ChangedFields: []string{"f1", "pk"},
+			Reason:        ReasonExpired,
+		},
+		{
+			Operation:     OperationUpdate,
+			New:           map[string]string{"pk": "1", "f1": "v2"},
+			Old:           map[string]string{"pk": "1", "f1": "v1"},
+			ChangedFields: []string{"f1"},
+			Reason:        ReasonWrite,
+		},
+		{
+			Operation:     OperationInsert,
+			New:           map[string]string{"pk": "0", "f1": "v3"},
+			ChangedFields: []string{"f1", "pk"},
+			Reason:        ReasonWrite,
+		},
+	}, changes, "changes")
+}
+
+func TestSTable_ExpiryTriggerErrors(t *testing.T) {
+	t.Parallel()
+	clock := newTestClock()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "f1": "v0"},
+	}, "pk", nil, nil, WithTTL(time.Minute), WithClock(clock.Now), WithSweepInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejectDelete := errors.New("delete is rejected")
+	var beforeDeletes []string
+	for i := 0; i < 2; i++ {
+		s.AddBeforeTrigger(testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+			if operation == OperationDelete {
+				beforeDeletes = append(beforeDeletes, old["pk"])
+				return nil, rejectDelete
+			}
+			return new, nil
+		}))
+	}
+	s.AddTrigger(TriggerFunc(func(operation int, new, old map[string]string) error {
+		if operation == OperationDelete {
+			return rejectDelete
+		}
+		return nil
+	}))
+	var reasons []ChangeReason
+	s.AddChangeTrigger(ChangeTriggerFunc(func(change Change) error {
+		if change.Operation == OperationDelete {
+			reasons = append(reasons, change.Reason)
+			return rejectDelete
+		}
+		return nil
+	}))
+
+	clock.Add(time.Minute)
+	affected, err := s.Insert([]map[string]string{{"pk": "1", "f1": "v1"}})
+	equal(t, nil, err, "insert error")
+	equal(t, 1, affected, "insert affected")
+	equal(t, []string{"0", "0"}, beforeDeletes, "every BEFORE trigger is called for expired row")
+	equal(t, []ChangeReason{ReasonExpired}, reasons, "trigger after failed one is called for expired row")
+	equal(t, []map[string]string{{"pk": "1", "f1": "v1"}}, s.Find(nil), "rows")
+
+	affected, err = s.Delete(map[string]string{"pk": "1"})
+	equal(t, rejectDelete, err, "delete error")
+	equal(t, 0, affected, "delete affected")
+}
+
+func TestSTable_ExpirySnapshot(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	snapshotPath := filepath.Join(dir, "snapshot")
+	walPath := filepath.Join(dir, "wal")
+	wal, err := OpenWAL(walPath, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := newTestClock()
+	s, err := NewSTable(nil, "pk", nil, nil,
+		WithTTL(time.Minute), WithClock(clock.Now), WithSweepInterval(0), WithWAL(wal), WithCheckpoint(snapshotPath, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(30 * time.Second)
+	_, err = s.Insert([]map[string]string{{"pk": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(30 * time.Second)
+
+	var buf bytes.Buffer
+	_, err = s.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []map[string]string{{"pk": "1"}}, loaded.Find(nil), "loaded rows")
+
+	equal(t, nil, s.Checkpoint(), "checkpoint")
+	recovered := recoverTable(t, snapshotPath, walPath)
+	equal(t, []map[string]string{{"pk": "1"}}, recovered.Find(nil), "recovered rows")
+	equal(t, nil, recovered.Close(), "close recovered")
+	equal(t, nil, s.Close(), "close")
+}
+
+func TestSTable_ExpiryField(t *testing.T) {
+	t.Parallel()
+	clock := newTestClock()
+	_, err := NewSTable([]map[string]string{
+		{"pk": "0", "expires": "tomorrow"},
+	}, "pk", nil, nil, WithExpiryField("expires"), WithClock(clock.Now))
+	equal(t, errors.New("invalid time \"tomorrow\" for field \"expires\""), err, "invalid initial time")
+
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "expires": "2020-01-01T00:01:00Z"},
+		{"pk": "1", "expires": "2020-01-01T02:00:00+01:00"},
+		{"pk": "2"},
+	}, "pk", nil, nil, WithExpiryField("expires"), WithClock(clock.Now), WithSweepInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "3", "expires": "never"}})
+	equal(t, errors.New("invalid time \"never\" for field \"expires\""), err, "invalid time")
+
+	clock.Add(time.Minute)
+	rows, err := s.Select(nil)
+	equal(t, nil, err, "select error")
+	equal(t, []map[string]string{
+		{"pk": "1", "expires": "2020-01-01T02:00:00+01:00"},
+		{"pk": "2"},
+	}, rows, "select")
+	affected, err := s.Delete(map[string]string{"pk": "0"})
+	equal(t, nil, err, "delete expired row error")
+	equal(t, 0, affected, "delete expired row affected")
+	clock.Add(time.Hour)
+	_, err = s.SelectAny(map[string]string{"pk": "1"})
+	equal(t, sql.ErrNoRows, err, "select expired row")
+	equal(t, uint64(0), s.LastSeq(), "expired rows are deleted by writes only")
+}
+
+func TestSTable_ExpirySweeper(t *testing.T) {
+	t.Parallel()
+	clock := newTestClock()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0"},
+		{"pk": "1", "expires": "2020-01-01T00:00:30Z"},
+	}, "pk", nil, nil, WithTTL(time.Minute), WithExpiryField("expires"),
+		WithClock(clock.Now), WithSweepInterval(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := make(chan Change, 2)
+	s.AddChangeTrigger(ChangeTriggerFunc(func(change Change) error {
+		deleted <- change
+		return nil
+	}))
+	clock.Add(30 * time.Second)
+	equal(t, Change{
+		Operation:     OperationDelete,
+		Old:           map[string]string{"pk": "1", "expires": "2020-01-01T00:00:30Z"},
+		ChangedFields: []string{"expires", "pk"},
+		Reason:        ReasonExpired,
+	}, <-deleted, "expired by field")
+	clock.Add(30 * time.Second)
+	equal(t, Change{
+		Operation:     OperationDelete,
+		Old:           map[string]string{"pk": "0"},
+		ChangedFields: []string{"pk"},
+		Reason:        ReasonExpired,
+	}, <-deleted, "expired by TTL")
+
+	equal(t, nil, s.Close(), "close")
+	_, err = s.Insert([]map[string]string{{"pk": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Add(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	equal(t, uint64(3), s.LastSeq(), "sweeper is stopped by close")
+}
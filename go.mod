@@ -0,0 +1,3 @@
+module github.com/krpn/stable
+
+go 1.19
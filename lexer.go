@@ -0,0 +1,242 @@
+package stable
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError represents error of parsing query at the position.
+type SyntaxError struct {
+	// Pos is the byte offset of the error in the query.
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %v: %v", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenPlaceholder
+	tokenOperator
+	tokenComma
+	tokenSemicolon
+	tokenLParen
+	tokenRParen
+	tokenStar
+)
+
+type token struct {
+	kind tokenKind
+	// text is the value of identifier, number or string and the operator itself
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits query into tokens.
+// Identifiers may be quoted with backticks, strings are quoted with single or double quotes,
+// quote is escaped by doubling it.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for {
+		for pos < len(query) {
+			r, size := utf8.DecodeRuneInString(query[pos:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			pos += size
+		}
+		if pos == len(query) {
+			return append(tokens, token{kind: tokenEOF, pos: pos}), nil
+		}
+		t, end, err := lexToken(query, pos)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		pos = end
+	}
+}
+
+// lexToken returns token at pos and the position after it.
+func lexToken(query string, pos int) (token, int, error) {
+	c := query[pos]
+	switch {
+	case c == ',':
+		return token{kind: tokenComma, text: ",", pos: pos}, pos + 1, nil
+	case c == ';':
+		return token{kind: tokenSemicolon, text: ";", pos: pos}, pos + 1, nil
+	case c == '(':
+		return token{kind: tokenLParen, text: "(", pos: pos}, pos + 1, nil
+	case c == ')':
+		return token{kind: tokenRParen, text: ")", pos: pos}, pos + 1, nil
+	case c == '*':
+		return token{kind: tokenStar, text: "*", pos: pos}, pos + 1, nil
+	case c == '?':
+		return token{kind: tokenPlaceholder, text: "?", pos: pos}, pos + 1, nil
+	case c == '\'' || c == '"' || c == '`':
+		text, end, err := lexQuoted(query, pos)
+		if err != nil {
+			return token{}, 0, err
+		}
+		if c == '`' {
+			return token{kind: tokenIdent, text: text, pos: pos}, end, nil
+		}
+		return token{kind: tokenString, text: text, pos: pos}, end, nil
+	case strings.ContainsRune("=!<>", rune(c)):
+		for _, op := range []string{"!=", "<>", "<=", ">=", "=", "<", ">"} {
+			if strings.HasPrefix(query[pos:], op) {
+				return token{kind: tokenOperator, text: op, pos: pos}, pos + len(op), nil
+			}
+		}
+	case c == '-' || c == '.' || isDigit(c):
+		end := pos
+		if c == '-' {
+			end++
+		}
+		digits := 0
+		for end < len(query) && (isDigit(query[end]) || query[end] == '.') {
+			if query[end] != '.' {
+				digits++
+			}
+			end++
+		}
+		if digits != 0 {
+			return token{kind: tokenNumber, text: query[pos:end], pos: pos}, end, nil
+		}
+	default:
+		end := pos
+		for end < len(query) {
+			r, size := utf8.DecodeRuneInString(query[end:])
+			if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				break
+			}
+			end += size
+		}
+		if end > pos {
+			return token{kind: tokenIdent, text: query[pos:end], pos: pos}, end, nil
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(query[pos:])
+	return token{}, 0, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+}
+
+// lexQuoted returns unquoted text of quoted string at pos and the position after it.
+func lexQuoted(query string, pos int) (string, int, error) {
+	quote := query[pos]
+	var b strings.Builder
+	for i := pos + 1; i < len(query); i++ {
+		if query[i] != quote {
+			b.WriteByte(query[i])
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, &SyntaxError{Pos: pos, Msg: "unterminated quoted string"}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parser is a base of recursive descent parsers of queries.
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func newParser(query string) (*parser, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// isKeyword reports whether the next token is keyword, keywords are case insensitive.
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// acceptKeyword consumes the next token when it is keyword.
+func (p *parser) acceptKeyword(keyword string) bool {
+	if !p.isKeyword(keyword) {
+		return false
+	}
+	p.advance()
+	return true
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		return p.unexpected(keyword)
+	}
+	return nil
+}
+
+func (p *parser) accept(kind tokenKind) bool {
+	if p.peek().kind != kind {
+		return false
+	}
+	p.advance()
+	return true
+}
+
+func (p *parser) expect(kind tokenKind, expected string) (token, error) {
+	if p.peek().kind != kind {
+		return token{}, p.unexpected(expected)
+	}
+	return p.advance(), nil
+}
+
+func (p *parser) expectIdent() (string, error) {
+	t, err := p.expect(tokenIdent, "identifier")
+	return t.text, err
+}
+
+func (p *parser) expectEOF() error {
+	_, err := p.expect(tokenEOF, "end of query")
+	return err
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("expected %v, found %v", expected, t)}
+}
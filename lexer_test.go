@@ -0,0 +1,77 @@
+package stable
+
+import (
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase       string
+		query          string
+		expectedTokens []token
+		expectedErr    error
+	}
+	testTable := []testTableData{
+		{
+			testCase: "all kinds",
+			query:    "SELECT *, `my field` FROM t WHERE a>=-1.5 AND b != 'it''s' (?);",
+			expectedTokens: []token{
+				{kind: tokenIdent, text: "SELECT", pos: 0},
+				{kind: tokenStar, text: "*", pos: 7},
+				{kind: tokenComma, text: ",", pos: 8},
+				{kind: tokenIdent, text: "my field", pos: 10},
+				{kind: tokenIdent, text: "FROM", pos: 21},
+				{kind: tokenIdent, text: "t", pos: 26},
+				{kind: tokenIdent, text: "WHERE", pos: 28},
+				{kind: tokenIdent, text: "a", pos: 34},
+				{kind: tokenOperator, text: ">=", pos: 35},
+				{kind: tokenNumber, text: "-1.5", pos: 37},
+				{kind: tokenIdent, text: "AND", pos: 42},
+				{kind: tokenIdent, text: "b", pos: 46},
+				{kind: tokenOperator, text: "!=", pos: 48},
+				{kind: tokenString, text: "it's", pos: 51},
+				{kind: tokenLParen, text: "(", pos: 59},
+				{kind: tokenPlaceholder, text: "?", pos: 60},
+				{kind: tokenRParen, text: ")", pos: 61},
+				{kind: tokenSemicolon, text: ";", pos: 62},
+				{kind: tokenEOF, pos: 63},
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase: "unicode identifier and double quoted string",
+			query:    `имя="a""b"`,
+			expectedTokens: []token{
+				{kind: tokenIdent, text: "имя", pos: 0},
+				{kind: tokenOperator, text: "=", pos: 6},
+				{kind: tokenString, text: `a"b`, pos: 7},
+				{kind: tokenEOF, pos: 13},
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase:       "unterminated string",
+			query:          "a = 'b",
+			expectedTokens: nil,
+			expectedErr:    &SyntaxError{Pos: 4, Msg: "unterminated quoted string"},
+		},
+		{
+			testCase:       "unexpected character",
+			query:          "a # b",
+			expectedTokens: nil,
+			expectedErr:    &SyntaxError{Pos: 2, Msg: `unexpected character '#'`},
+		},
+		{
+			testCase:       "sign without digits",
+			query:          "a - b",
+			expectedTokens: nil,
+			expectedErr:    &SyntaxError{Pos: 2, Msg: `unexpected character '-'`},
+		},
+	}
+	for _, testUnit := range testTable {
+		tokens, err := lex(testUnit.query)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedTokens, tokens, testUnit.testCase)
+	}
+}
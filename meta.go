@@ -0,0 +1,68 @@
+package stable
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// rowMeta is hidden metadata of committed row.
+// Access fields are updated by reads under read lock, so they are atomic.
+type rowMeta struct {
+	changed  time.Time
+	version  uint64
+	inserted uint64
+	accessed atomic.Uint64
+	hits     atomic.Uint64
+}
+
+// resetMeta sets metadata of all rows as they are inserted now in order of rows.
+func (st *stable) resetMeta() {
+	now := st.now()
+	st.meta = make(map[string]*rowMeta, len(st.rows))
+	for _, row := range st.rows {
+		st.meta[row[st.primaryKeyField]] = st.newMeta(now)
+	}
+}
+
+// newMeta returns metadata of inserted row, versions are taken from the tick of STable,
+// so row deleted and inserted again never gets version it had before.
+func (st *stable) newMeta(now time.Time) *rowMeta {
+	tick := st.tick.Add(1)
+	meta := &rowMeta{changed: now, version: tick, inserted: tick}
+	meta.accessed.Store(tick)
+	return meta
+}
+
+// updateMeta updates metadata of rows by committed changes.
+func (st *stable) updateMeta(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	now := st.now()
+	for _, change := range changes {
+		switch change.Operation {
+		case OperationInsert:
+			st.meta[change.New[st.primaryKeyField]] = st.newMeta(now)
+		case OperationUpdate:
+			meta := st.meta[change.New[st.primaryKeyField]]
+			meta.changed = now
+			meta.version = st.tick.Add(1)
+			meta.accessed.Store(meta.version)
+		case OperationDelete:
+			delete(st.meta, change.Old[st.primaryKeyField])
+		}
+	}
+}
+
+// touch records read access to row when eviction policy depends on it.
+func (st *stable) touch(row map[string]string) {
+	if st.eviction != EvictionLRU && st.eviction != EvictionLFU {
+		return
+	}
+	meta, ok := st.meta[row[st.primaryKeyField]]
+	if !ok {
+		return
+	}
+	meta.accessed.Store(st.tick.Add(1))
+	meta.hits.Add(1)
+}
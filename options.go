@@ -0,0 +1,97 @@
+package stable
+
+import (
+	"time"
+)
+
+// Option configures STable created by NewSTable.
+type Option func(st *stable)
+
+// WithName sets name of STable which is reported in every Change.
+func WithName(name string) Option {
+	return func(st *stable) {
+		st.name = name
+	}
+}
+
+// WithChangeLog enables in-memory log of size last committed changes, see STable.ChangesSince.
+func WithChangeLog(size int) Option {
+	return func(st *stable) {
+		st.changeLogSize = size
+	}
+}
+
+// WithWAL makes STable write every committed change to the write-ahead log before the operation returns.
+// Changes already in the log which follow the initial state of STable are replayed by NewSTable or Load,
+// so initial rows of NewSTable must be the state the log starts from. STable.Close closes the log.
+func WithWAL(wal *WAL) Option {
+	return func(st *stable) {
+		st.wal = wal
+	}
+}
+
+// WithCheckpoint enables checkpoints of STable: JSON snapshot is written to snapshotPath
+// and the write-ahead log is compacted up to it, see STable.Checkpoint.
+// Background checkpoints are made every interval and when the log grows to walSize bytes,
+// zero values disable them, see STable.CheckpointErr for their errors. STable.Close stops background checkpoints and makes the final one.
+func WithCheckpoint(snapshotPath string, interval time.Duration, walSize int64) Option {
+	return func(st *stable) {
+		st.snapshotPath = snapshotPath
+		st.checkpointInterval = interval
+		st.checkpointWALSize = walSize
+	}
+}
+
+// withSeq sets sequence number of the last committed change.
+func withSeq(seq uint64) Option {
+	return func(st *stable) {
+		st.seq = seq
+	}
+}
+
+// WithTTL makes rows expire ttl after they were inserted or last changed.
+// Expired rows are not visible to reads and are deleted before the next write or by the sweeper,
+// see WithSweepInterval. Time of change is not persisted: rows of NewSTable, Load and the write-ahead log
+// are treated as changed when STable is created.
+func WithTTL(ttl time.Duration) Option {
+	return func(st *stable) {
+		st.ttl = ttl
+	}
+}
+
+// WithExpiryField makes rows expire at RFC 3339 time in field the same way as WithTTL.
+// Rows with absent or empty field never expire, other values of field are checked as constraints.
+func WithExpiryField(field string) Option {
+	return func(st *stable) {
+		st.expiryField = field
+		st.validators = append(st.validators, newValueTimeValidator(field))
+	}
+}
+
+// WithSweepInterval sets how often expired rows are deleted in background, one minute is used by default.
+// Zero interval disables the sweeper, so expired rows are deleted only before writes. STable.Close stops the sweeper.
+func WithSweepInterval(interval time.Duration) Option {
+	return func(st *stable) {
+		st.sweepInterval = interval
+	}
+}
+
+// WithClock sets function returning the current time for row expiry, time.Now is used by default.
+// It is called concurrently by reads, so it must be safe for concurrent use.
+func WithClock(now func() time.Time) Option {
+	return func(st *stable) {
+		st.now = now
+	}
+}
+
+// WithMaxRows limits number of rows in STable to max.
+// Write exceeding the limit evicts rows by policy in the same commit or fails with *TableFullError
+// for EvictionReject. Rows written by the operation itself are never evicted.
+// Triggers can not reject deletes of evicted rows, see ChangeReason.
+// NewSTable and Load fail with *TableFullError when initial rows exceed the limit.
+func WithMaxRows(max int, policy EvictionPolicy) Option {
+	return func(st *stable) {
+		st.maxRows = max
+		st.eviction = policy
+	}
+}
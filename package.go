@@ -1,17 +1,29 @@
 package stable
 
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
 // STable represents simple string table engine.
 // All rows are stored as a map[string]string.
 // Primary key and constraints are supported.
 // Triggers are supported.
 //
 // It is safe calling STable methods from concurrently running goroutines.
+// Triggers are called under STable lock, so they must not call STable methods.
 type STable interface {
+	// Name returns name of STable, see WithName.
+	Name() string
+
 	// Insert inserts rows with constraints checks.
 	Insert(rows []map[string]string) (int, error)
 
 	// Insert inserts or updates rows (based on primary key) with constraints checks.
 	// Fields of updated rows will be merged instead of row to be fully replaced.
+	// Constraints are checked on merged rows after BEFORE triggers, so a row updating existing one
+	// may omit non-empty fields it does not change. Rows with the same primary key are rejected.
 	Upsert(rows []map[string]string) (int, error)
 
 	// Update updates rows based on conditions with constraints checks.
@@ -21,18 +33,154 @@ type STable interface {
 	// Delete deletes rows by conditions.
 	Delete(where map[string]string) (int, error)
 
+	// UpdateWhere is like Update but updates rows matched by condition, see ParseCondition.
+	UpdateWhere(fields map[string]string, cond Condition) (int, error)
+
+	// DeleteWhere is like Delete but deletes rows matched by condition, see ParseCondition.
+	DeleteWhere(cond Condition) (int, error)
+
+	// InsertContext is like Insert but gives up waiting for the lock when ctx is done
+	// and passes ctx to context triggers.
+	InsertContext(ctx context.Context, rows []map[string]string) (int, error)
+
+	// UpsertContext is like Upsert but gives up waiting for the lock when ctx is done
+	// and passes ctx to context triggers.
+	UpsertContext(ctx context.Context, rows []map[string]string) (int, error)
+
+	// UpdateContext is like Update but gives up waiting for the lock when ctx is done
+	// and passes ctx to context triggers.
+	UpdateContext(ctx context.Context, fields map[string]string, where map[string]string) (int, error)
+
+	// DeleteContext is like Delete but gives up waiting for the lock when ctx is done
+	// and passes ctx to context triggers.
+	DeleteContext(ctx context.Context, where map[string]string) (int, error)
+
 	// Select selects rows by conditions.
 	// sql.ErrNoRows will be throwed when no rows found.
 	Select(where map[string]string) (rows []map[string]string, err error)
 
+	// SelectContext is like Select but gives up waiting for the lock when ctx is done.
+	SelectContext(ctx context.Context, where map[string]string) (rows []map[string]string, err error)
+
+	// SelectWhere selects rows matched by condition, see ParseCondition.
+	// sql.ErrNoRows will be throwed when no rows found.
+	SelectWhere(cond Condition) (rows []map[string]string, err error)
+
 	// SelectAny selects one random row by conditions.
 	// sql.ErrNoRows will be throwed when no rows found.
 	SelectAny(where map[string]string) (row map[string]string, err error)
 
-	// AddTrigger adds trigger to STable.
-	AddTrigger(trigger Trigger)
+	// WaitFor selects one row by conditions.
+	// When no rows found, it blocks until a committed change produces a matched row or ctx is done.
+	WaitFor(ctx context.Context, where map[string]string) (row map[string]string, err error)
+
+	// Find selects rows by conditions.
+	// Unlike Select, empty result is not an error: an empty non-nil slice is returned.
+	Find(where map[string]string) []map[string]string
+
+	// Get selects row by primary key value.
+	// The second value reports whether the row was found.
+	Get(pk string) (row map[string]string, ok bool)
+
+	// GetVersion is like Get but also returns version of row, see UpdateIfVersion.
+	// Version is positive and it is increased by every change of row, not necessarily by one.
+	// Versions are never reused within STable, even by row deleted and inserted again.
+	// Versions are not persisted: rows of NewSTable, Load and the write-ahead log get new versions.
+	GetVersion(pk string) (row map[string]string, version uint64, ok bool)
+
+	// UpdateIfVersion updates row by primary key with constraints checks only when row has version,
+	// so concurrent changes made since row was read are not overwritten.
+	// *ConflictError is returned when row is not found or has other version.
+	// It returns version of row after update, which is not changed when fields do not change row,
+	// or the current version on conflict.
+	UpdateIfVersion(pk string, version uint64, fields map[string]string) (uint64, error)
+
+	// Query returns a cursor over rows matched by conditions.
+	// Unlike Select, rows are not collected to a slice and empty result is not an error.
+	Query(where map[string]string) *Rows
+
+	// Distinct returns sorted distinct non-empty values of field in rows matched by conditions.
+	Distinct(field string, where map[string]string) []string
+
+	// DistinctCount returns distinct non-empty values of field in rows matched by conditions
+	// with the number of rows for every value.
+	DistinctCount(field string, where map[string]string) map[string]int
+
+	// AddTrigger adds enabled trigger to STable.
+	AddTrigger(trigger Trigger) TriggerID
+
+	// AddChangeTrigger adds enabled trigger to STable.
+	AddChangeTrigger(trigger ChangeTrigger) TriggerID
+
+	// AddContextTrigger adds enabled trigger to STable.
+	AddContextTrigger(trigger ContextTrigger) TriggerID
+
+	// AddFilteredTrigger adds enabled trigger to STable which is called only for changes matched by filter.
+	AddFilteredTrigger(trigger Trigger, filter TriggerFilter) TriggerID
+
+	// AddBeforeTrigger adds enabled trigger called before constraints checks to STable.
+	AddBeforeTrigger(trigger BeforeTrigger) TriggerID
+
+	// AddAfterTrigger adds enabled trigger called after changes are committed to STable.
+	AddAfterTrigger(trigger AfterTrigger) TriggerID
+
+	// AddStatementTrigger adds enabled trigger called once per STable operation
+	// after its changes are committed.
+	AddStatementTrigger(trigger StatementTrigger) TriggerID
+
+	// RemoveTrigger removes trigger of any kind from STable.
+	// It returns false when trigger is not found.
+	RemoveTrigger(id TriggerID) bool
+
+	// EnableTrigger enables disabled trigger.
+	// It returns false when trigger is not found.
+	EnableTrigger(id TriggerID) bool
+
+	// DisableTrigger disables trigger, disabled trigger is not called until enabled again.
+	// It returns false when trigger is not found.
+	DisableTrigger(id TriggerID) bool
+
+	// Triggers lists triggers of all kinds in order they were added.
+	Triggers() []TriggerInfo
+
+	// LastSeq returns sequence number of the last committed change.
+	LastSeq() uint64
+
+	// ChangesSince returns committed changes with sequence numbers greater than seq from the change log,
+	// see WithChangeLog. ErrChangeLogTruncated is returned when some of them are no longer in the log.
+	ChangesSince(seq uint64) ([]Change, error)
+
+	// WriteTo writes JSON snapshot of rows together with STable definition to w, see Load.
+	WriteTo(w io.Writer) (n int64, err error)
+
+	// WriteBinaryTo writes compact binary snapshot of rows together with STable definition to w, see Load.
+	// The snapshot is protected by checksum and optionally compressed.
+	WriteBinaryTo(w io.Writer, compression Compression) (n int64, err error)
+
+	// Checkpoint writes snapshot of STable and compacts the write-ahead log up to it, see WithCheckpoint.
+	// Writers are blocked only while the log is compacted.
+	Checkpoint() error
+
+	// CheckpointErr returns error of the last background checkpoint, nil when it succeeded.
+	// The write-ahead log is not compacted while background checkpoints fail.
+	CheckpointErr() error
+
+	// Close stops background work, makes the final checkpoint and closes the write-ahead log.
+	// STable without them does not need to be closed.
+	Close() error
+
+	// Subscribe starts asynchronous delivery of committed changes of rows matched by conditions.
+	// Change matches when its new or old row matches conditions.
+	// Changes are buffered up to bufSize, policy defines what happens when buffer is full.
+	// Delivery stops and the channel is closed when ctx is done. Conditions are copied.
+	Subscribe(ctx context.Context, where map[string]string, bufSize int, policy OverflowPolicy) *Subscription
 }
 
+// Operation represents STable event for a Trigger.
+// Trigger receives it as int for compatibility, Change and the other trigger kinds receive it as Operation.
+type Operation int
+
+// Operation constants are untyped, so they may be compared with both Operation and int passed to Trigger.
 const (
 	// OperationInsert represents insert event constant for a Trigger.
 	OperationInsert = iota
@@ -42,8 +190,167 @@ const (
 	OperationDelete
 )
 
-// Trigger is a Handler called on STable events.
+func (o Operation) String() string {
+	switch o {
+	case OperationInsert:
+		return "INSERT"
+	case OperationUpdate:
+		return "UPDATE"
+	case OperationDelete:
+		return "DELETE"
+	}
+	return fmt.Sprintf("Operation(%d)", int(o))
+}
+
+// Trigger is a Handler called on STable events with copies of rows.
 // Note: trigger not called when updated row is not changed.
 type Trigger interface {
 	Handle(operation int, new, old map[string]string) error
 }
+
+// TriggerFunc is an adapter to allow the use of ordinary functions as a Trigger.
+type TriggerFunc func(operation int, new, old map[string]string) error
+
+// Handle calls f(operation, new, old).
+func (f TriggerFunc) Handle(operation int, new, old map[string]string) error {
+	return f(operation, new, old)
+}
+
+// ChangeTrigger is a Handler called on STable events like a Trigger,
+// but it receives the whole Change with copies of rows.
+// Note: trigger not called when updated row is not changed.
+type ChangeTrigger interface {
+	HandleChange(change Change) error
+}
+
+// ChangeTriggerFunc is an adapter to allow the use of ordinary functions as a ChangeTrigger.
+type ChangeTriggerFunc func(change Change) error
+
+// HandleChange calls f(change).
+func (f ChangeTriggerFunc) HandleChange(change Change) error {
+	return f(change)
+}
+
+// ContextTrigger is a ChangeTrigger which receives ctx of the operation.
+// Operations without ctx pass context.Background().
+// Note: trigger not called when updated row is not changed.
+type ContextTrigger interface {
+	HandleContext(ctx context.Context, change Change) error
+}
+
+// ContextTriggerFunc is an adapter to allow the use of ordinary functions as a ContextTrigger.
+type ContextTriggerFunc func(ctx context.Context, change Change) error
+
+// HandleContext calls f(ctx, change).
+func (f ContextTriggerFunc) HandleContext(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
+
+// TriggerID identifies trigger added to STable.
+type TriggerID uint64
+
+// TriggerInfo describes trigger added to STable.
+// Trigger holds one of Trigger, ChangeTrigger, ContextTrigger, BeforeTrigger, AfterTrigger or StatementTrigger.
+// Filter is nil for triggers added without filter.
+type TriggerInfo struct {
+	ID      TriggerID
+	Trigger interface{}
+	Filter  *TriggerFilter
+	Enabled bool
+}
+
+// TriggerFilter restricts changes a Trigger is called for.
+// Empty filter matches all changes.
+type TriggerFilter struct {
+	// Operations lists operations to call trigger for, empty list means all operations.
+	Operations []Operation
+	// Fields lists fields at least one of which must be changed by OperationUpdate
+	// (like UPDATE OF in SQL), empty list means any fields. It does not affect other operations.
+	Fields []string
+	// Where are conditions for new row of OperationInsert and OperationUpdate
+	// or for old row of OperationDelete.
+	Where map[string]string
+}
+
+// BeforeTrigger is a Handler called on STable events before constraints checks.
+// It receives copies of rows and may return a modified new row which is stored
+// instead of the original one and then goes through constraints checks.
+// Returned nil row leaves the row unchanged; returned row is ignored for OperationDelete.
+// Returned error rejects the whole operation.
+// Note: trigger not called when updated row is not changed.
+type BeforeTrigger interface {
+	HandleBefore(operation Operation, new, old map[string]string) (map[string]string, error)
+}
+
+// AfterTrigger is a Handler called once per STable operation after its changes are committed.
+// Changes are ordered as inserted and updated rows followed by deleted rows and contain copies of rows.
+// Operations without changes do not call trigger.
+//
+// AFTER trigger can not reject committed changes: when it returns an error,
+// remaining AFTER triggers are not called and the operation returns *AfterTriggerError
+// along with the number of affected rows.
+type AfterTrigger interface {
+	HandleAfter(changes []Change) error
+}
+
+// Change represents a change of one STable row.
+// New is nil for OperationDelete, Old is nil for OperationInsert.
+type Change struct {
+	Operation Operation
+	New, Old  map[string]string
+	// ChangedFields are sorted names of fields added, removed or modified by the change.
+	ChangedFields []string
+	// Table is the name of STable, see WithName.
+	Table string
+	// Seq is the sequence number of committed change, it is increased by one for every change.
+	// Seq is zero for changes passed to triggers called before commit.
+	Seq uint64
+	// Reason tells why the change was made.
+	Reason ChangeReason
+}
+
+// ChangeReason represents why a Change was made.
+// Deletes with reasons other than ReasonWrite are made by STable itself and can not be rejected by triggers:
+// errors returned for them by BEFORE, row, change and context triggers are ignored and the following triggers are called.
+type ChangeReason int
+
+const (
+	// ReasonWrite represents change made by STable operation.
+	ReasonWrite ChangeReason = iota
+	// ReasonExpired represents delete of expired row, see WithTTL and WithExpiryField.
+	ReasonExpired
+	// ReasonEvicted represents delete of row evicted to free space, see WithMaxRows.
+	ReasonEvicted
+)
+
+func (r ChangeReason) String() string {
+	switch r {
+	case ReasonWrite:
+		return "WRITE"
+	case ReasonExpired:
+		return "EXPIRED"
+	case ReasonEvicted:
+		return "EVICTED"
+	}
+	return fmt.Sprintf("ChangeReason(%d)", int(r))
+}
+
+// StatementTrigger is a Handler called once per STable operation after its changes are committed.
+// It receives all changes of the operation grouped by kind.
+// Operations without changes do not call trigger.
+// Errors are handled the same way as AfterTrigger errors.
+type StatementTrigger interface {
+	HandleStatement(changes ChangeSet) error
+}
+
+// ChangeSet represents all changes of one STable operation.
+type ChangeSet struct {
+	Inserted []map[string]string
+	Updated  []RowUpdate
+	Deleted  []map[string]string
+}
+
+// RowUpdate represents new and old values of updated row.
+type RowUpdate struct {
+	New, Old map[string]string
+}
@@ -0,0 +1,56 @@
+package stable
+
+// Rows is a cursor over rows selected by STable.Query.
+// Rows are read from the snapshot of STable taken when the query was started,
+// so changes committed after that are not visible and no lock is held while iterating.
+//
+// Rows is not safe for concurrent use.
+type Rows struct {
+	snapshot []map[string]string
+	where    map[string]string
+	row      map[string]string
+	next     int
+	closed   bool
+}
+
+func newRows(snapshot []map[string]string, where map[string]string) *Rows {
+	return &Rows{snapshot: snapshot, where: where}
+}
+
+// Next advances the cursor to the next matched row.
+// It returns false when there are no more rows or Rows is closed.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	for r.next < len(r.snapshot) {
+		row := r.snapshot[r.next]
+		r.next++
+		if matches(row, r.where) {
+			r.row = copyRow(row)
+			return true
+		}
+	}
+	r.row = nil
+	return false
+}
+
+// Row returns a copy of the current row.
+// It returns nil before the first call of Next and after Next returned false.
+func (r *Rows) Row() map[string]string {
+	return r.row
+}
+
+// Err returns the error encountered during iteration, if any.
+func (r *Rows) Err() error {
+	return nil
+}
+
+// Close stops iteration and releases the snapshot.
+// It is safe to call Close multiple times and to stop iteration early.
+func (r *Rows) Close() error {
+	r.closed = true
+	r.snapshot = nil
+	r.row = nil
+	return nil
+}
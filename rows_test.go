@@ -0,0 +1,177 @@
+package stable
+
+import (
+	"testing"
+)
+
+func TestSTable_Query(t *testing.T) {
+	t.Parallel()
+	primaryKeyField := "pk"
+	nonEmptyFields := []string{}
+	uniqFields := []string{}
+	initRows := []map[string]string{
+		{"pk": "0", "f1": "v0"},
+		{"pk": "1", "f1": "v1"},
+		{"pk": "2", "f1": "v0"},
+	}
+	type testTableData struct {
+		testCase     string
+		where        map[string]string
+		limit        int
+		expectedRows []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase: "all rows",
+			where:    nil,
+			limit:    -1,
+			expectedRows: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+				{"pk": "1", "f1": "v1"},
+				{"pk": "2", "f1": "v0"},
+			},
+		},
+		{
+			testCase: "filtered rows",
+			where:    map[string]string{"f1": "v0"},
+			limit:    -1,
+			expectedRows: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+				{"pk": "2", "f1": "v0"},
+			},
+		},
+		{
+			testCase:     "no rows",
+			where:        map[string]string{"f1": "v2"},
+			limit:        -1,
+			expectedRows: nil,
+		},
+		{
+			testCase: "early termination",
+			where:    nil,
+			limit:    1,
+			expectedRows: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+			},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable(initRows, primaryKeyField, nonEmptyFields, uniqFields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := s.Query(testUnit.where)
+		var actual []map[string]string
+		for rows.Next() {
+			actual = append(actual, rows.Row())
+			if len(actual) == testUnit.limit {
+				equal(t, nil, rows.Close(), testUnit.testCase)
+			}
+		}
+		equal(t, nil, rows.Err(), testUnit.testCase)
+		equal(t, nil, rows.Close(), testUnit.testCase)
+		equal(t, testUnit.expectedRows, actual, testUnit.testCase)
+		equal(t, false, rows.Next(), testUnit.testCase)
+	}
+}
+
+func TestSTable_QuerySnapshot(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0"}, {"pk": "1"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := s.Query(nil)
+	if !rows.Next() {
+		t.Fatal("no rows")
+	}
+	rows.Row()["pk"] = "changed"
+	_, err = s.Delete(map[string]string{"pk": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []map[string]string
+	for rows.Next() {
+		actual = append(actual, rows.Row())
+	}
+	equal(t, []map[string]string{{"pk": "1"}}, actual, "rows from snapshot")
+	selected, err := s.Select(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []map[string]string{{"pk": "0"}, {"pk": "2"}}, selected, "row changes are not leaked")
+}
+
+func TestSTable_QuerySnapshotTrigger(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "f1": "v0"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddTrigger(TriggerFunc(func(operation int, new, old map[string]string) error {
+		if old != nil {
+			old["f1"] = "changed"
+		}
+		return nil
+	}))
+	rows := s.Query(nil)
+	_, err = s.Delete(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []map[string]string
+	for rows.Next() {
+		actual = append(actual, rows.Row())
+	}
+	equal(t, []map[string]string{{"pk": "0", "f1": "v0"}}, actual, "trigger does not change snapshot")
+}
+
+func TestSTable_QuerySnapshotWrittenRows(t *testing.T) {
+	t.Parallel()
+	initial := map[string]string{"pk": "0", "f1": "v0"}
+	inserted := map[string]string{"pk": "1", "f1": "v1"}
+	upserted := map[string]string{"pk": "2", "f1": "v2"}
+	var returned map[string]string
+	s, err := NewSTable([]map[string]string{initial}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AddBeforeTrigger(testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+		if new["pk"] == "3" {
+			returned = new
+		}
+		return new, nil
+	}))
+	_, err = s.Insert([]map[string]string{inserted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Upsert([]map[string]string{upserted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "3", "f1": "v3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := s.Query(nil)
+	for _, row := range []map[string]string{initial, inserted, upserted, returned} {
+		row["f1"] = "mutated"
+	}
+	var actual []map[string]string
+	for rows.Next() {
+		actual = append(actual, rows.Row())
+	}
+	expected := []map[string]string{
+		{"pk": "0", "f1": "v0"},
+		{"pk": "1", "f1": "v1"},
+		{"pk": "2", "f1": "v2"},
+		{"pk": "3", "f1": "v3"},
+	}
+	equal(t, expected, actual, "written rows do not change snapshot")
+	equal(t, expected, s.Find(nil), "written rows do not change table")
+}
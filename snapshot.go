@@ -0,0 +1,86 @@
+package stable
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const snapshotVersion = 1
+
+// snapshot represents rows together with STable definition.
+type snapshot struct {
+	Version         int                 `json:"version"`
+	Name            string              `json:"name,omitempty"`
+	PrimaryKeyField string              `json:"primaryKey"`
+	NonEmptyFields  []string            `json:"nonEmptyFields,omitempty"`
+	UniqFields      []string            `json:"uniqFields,omitempty"`
+	Seq             uint64              `json:"seq,omitempty"`
+	Rows            []map[string]string `json:"rows"`
+}
+
+// snapshot returns consistent snapshot of STable, committed rows are copies of written rows never modified in place,
+// so it is safe to use it without the lock. Expired rows are not included, they would not expire after Load.
+func (st *stable) snapshot() snapshot {
+	st.RLock()
+	defer st.RUnlock()
+	return snapshot{
+		Version:         snapshotVersion,
+		Name:            st.name,
+		PrimaryKeyField: st.primaryKeyField,
+		NonEmptyFields:  st.nonEmptyFields,
+		UniqFields:      st.uniqFields,
+		Seq:             st.seq,
+		Rows:            st.visibleRows(),
+	}
+}
+
+func (st *stable) WriteTo(w io.Writer) (int64, error) {
+	s := st.snapshot()
+	cw := &countingWriter{w: w}
+	err := json.NewEncoder(cw).Encode(s)
+	return cw.n, err
+}
+
+// Load creates new STable from JSON snapshot written by STable.WriteTo
+// or binary snapshot written by STable.WriteBinaryTo, the format is detected by the content.
+// Rows are checked by constraints the same way as by NewSTable.
+// Options are applied after the name from snapshot, so WithName overrides it.
+func Load(r io.Reader, opts ...Option) (STable, error) {
+	br := bufio.NewReader(r)
+	var s snapshot
+	var err error
+	if magic, _ := br.Peek(len(binaryMagic)); string(magic) == binaryMagic {
+		s, err = readBinarySnapshot(br)
+	} else {
+		err = json.NewDecoder(br).Decode(&s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.table(opts)
+}
+
+func (s snapshot) table(opts []Option) (STable, error) {
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %v", s.Version)
+	}
+	opts = append([]Option{WithName(s.Name), withSeq(s.Seq)}, opts...)
+	st, err := NewSTable(s.Rows, s.PrimaryKeyField, s.NonEmptyFields, s.UniqFields, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
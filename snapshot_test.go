@@ -0,0 +1,72 @@
+package stable
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSTable_WriteToLoad(t *testing.T) {
+	t.Parallel()
+	rows := []map[string]string{
+		{"pk": "0", "name": "Alex", "phone": "112233"},
+		{"pk": "1", "name": "John", "phone": "223344", "city": "London"},
+	}
+	s, err := NewSTable(rows, "pk", []string{"name"}, []string{"phone"}, WithName("customers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := s.WriteTo(&buf)
+	equal(t, nil, err, "write error")
+	equal(t, int64(buf.Len()), n, "written bytes")
+
+	loaded, err := Load(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "customers", loaded.Name(), "loaded name")
+	equal(t, rows, loaded.Find(nil), "loaded rows")
+	_, err = loaded.Insert([]map[string]string{{"pk": "2", "name": "Bill", "phone": "112233"}})
+	equal(t, errors.New("duplicate value \"112233\" for field \"phone\""), err, "loaded uniq fields")
+	_, err = loaded.Insert([]map[string]string{{"pk": "2", "phone": "334455"}})
+	equal(t, errors.New("empty value for field \"name\""), err, "loaded non empty fields")
+
+	loaded, err = Load(bytes.NewReader(buf.Bytes()), WithName("renamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "renamed", loaded.Name(), "name option overrides snapshot")
+}
+
+func TestLoad_Errors(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase    string
+		data        string
+		expectedErr error
+	}
+	testTable := []testTableData{
+		{
+			testCase:    "unsupported version",
+			data:        `{"version":2,"primaryKey":"pk","rows":[]}`,
+			expectedErr: errors.New("unsupported snapshot version 2"),
+		},
+		{
+			testCase:    "empty primary key",
+			data:        `{"version":1,"rows":[]}`,
+			expectedErr: errors.New("primary key is empty"),
+		},
+		{
+			testCase:    "constraints",
+			data:        `{"version":1,"primaryKey":"pk","rows":[{"pk":"0"},{"pk":"0"}]}`,
+			expectedErr: errors.New("duplicate value \"0\" for field \"pk\""),
+		},
+	}
+	for _, testUnit := range testTable {
+		st, err := Load(strings.NewReader(testUnit.data))
+		equal(t, nil, st, testUnit.testCase)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+	}
+}
@@ -0,0 +1,360 @@
+package stable
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DriverName is the name of database/sql driver over registered tables.
+//
+//	stable.RegisterTable("customers", st)
+//	db, err := sql.Open(stable.DriverName, "")
+//	row := db.QueryRow("SELECT name FROM customers WHERE id = ?", 1)
+//
+// The driver supports a small SQL subset:
+//
+//	SELECT * | column [, ...] FROM table [WHERE column = value [AND ...]] [ORDER BY column [ASC | DESC] [, ...]] [LIMIT count]
+//	INSERT INTO table (column [, ...]) VALUES (value [, ...]) [, ...]
+//	UPDATE table SET column = value [, ...] [WHERE column = value [AND ...]]
+//	DELETE FROM table [WHERE column = value [AND ...]]
+//
+// Values are string or number literals, NULL or ? placeholders, they are converted to strings.
+// Absent fields are returned as NULL and inserted NULL values become absent fields.
+// ORDER BY compares values as numbers when both are numbers and as strings otherwise, absent fields go first.
+// SELECT * returns all fields of selected rows in sorted order.
+// Data source name is not used: every connection sees all registered tables. Transactions are not supported.
+const DriverName = "stable"
+
+func init() {
+	sql.Register(DriverName, &sqlDriver{})
+}
+
+var sqlTables = struct {
+	sync.RWMutex
+	tables map[string]STable
+}{tables: make(map[string]STable)}
+
+// RegisterTable makes st available to the SQL driver as table with name.
+// Registered table with the same name is replaced.
+func RegisterTable(name string, st STable) {
+	sqlTables.Lock()
+	defer sqlTables.Unlock()
+	sqlTables.tables[name] = st
+}
+
+// UnregisterTable makes table with name unavailable to the SQL driver.
+func UnregisterTable(name string) {
+	sqlTables.Lock()
+	defer sqlTables.Unlock()
+	delete(sqlTables.tables, name)
+}
+
+func registeredTable(name string) (STable, error) {
+	sqlTables.RLock()
+	defer sqlTables.RUnlock()
+	st, ok := sqlTables.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("table %q is not registered", name)
+	}
+	return st, nil
+}
+
+type sqlDriver struct{}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	return &sqlConn{}, nil
+}
+
+type sqlConn struct{}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	stmt, err := parseSQL(query)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{stmt: stmt}, nil
+}
+
+func (c *sqlConn) Close() error {
+	return nil
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type sqlStmt struct {
+	stmt *sqlStatement
+}
+
+func (s *sqlStmt) Close() error {
+	return nil
+}
+
+func (s *sqlStmt) NumInput() int {
+	return s.stmt.numInput
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if s.stmt.kind == sqlSelect {
+		return nil, errors.New("SELECT must be executed as query")
+	}
+	values, err := argValues(args)
+	if err != nil {
+		return nil, err
+	}
+	st, err := registeredTable(s.stmt.table)
+	if err != nil {
+		return nil, err
+	}
+	var affected int
+	switch s.stmt.kind {
+	case sqlInsert:
+		affected, err = s.insert(ctx, st, values)
+	case sqlUpdate:
+		affected, err = s.update(ctx, st, values)
+	case sqlDelete:
+		affected, err = s.delete(ctx, st, values)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if s.stmt.kind != sqlSelect {
+		return nil, errors.New("only SELECT may be executed as query")
+	}
+	values, err := argValues(args)
+	if err != nil {
+		return nil, err
+	}
+	st, err := registeredTable(s.stmt.table)
+	if err != nil {
+		return nil, err
+	}
+	where, ok, err := s.where(values)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]string
+	if ok {
+		rows, err = st.SelectContext(ctx, where)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	s.sort(rows)
+	if s.stmt.limit != nil {
+		limit, err := s.limit(values)
+		if err != nil {
+			return nil, err
+		}
+		if limit < len(rows) {
+			rows = rows[:limit]
+		}
+	}
+	columns := s.stmt.columns
+	if columns == nil {
+		columns = rowsFields(rows)
+	}
+	return &sqlRows{columns: columns, rows: rows}, nil
+}
+
+func (s *sqlStmt) insert(ctx context.Context, st STable, values []*string) (int, error) {
+	rows := make([]map[string]string, len(s.stmt.values))
+	for i, rowValues := range s.stmt.values {
+		row := make(map[string]string, len(s.stmt.columns))
+		for j, column := range s.stmt.columns {
+			value := resolveValue(rowValues[j], values)
+			if value != nil {
+				row[column] = *value
+			}
+		}
+		rows[i] = row
+	}
+	return st.InsertContext(ctx, rows)
+}
+
+func (s *sqlStmt) update(ctx context.Context, st STable, values []*string) (int, error) {
+	fields := make(map[string]string, len(s.stmt.set))
+	for _, assignment := range s.stmt.set {
+		value := resolveValue(assignment.value, values)
+		if value == nil {
+			return 0, fmt.Errorf("NULL value of column %q is not supported in SET", assignment.field)
+		}
+		fields[assignment.field] = *value
+	}
+	where, ok, err := s.where(values)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return st.UpdateContext(ctx, fields, where)
+}
+
+func (s *sqlStmt) delete(ctx context.Context, st STable, values []*string) (int, error) {
+	where, ok, err := s.where(values)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return st.DeleteContext(ctx, where)
+}
+
+// where returns conditions of statement.
+// The second value is false when conditions contradict each other, so no rows may match.
+func (s *sqlStmt) where(values []*string) (map[string]string, bool, error) {
+	where := make(map[string]string, len(s.stmt.where))
+	ok := true
+	for _, condition := range s.stmt.where {
+		value := resolveValue(condition.value, values)
+		if value == nil {
+			return nil, false, fmt.Errorf("NULL value of column %q is not supported in WHERE", condition.field)
+		}
+		if prev, found := where[condition.field]; found && prev != *value {
+			ok = false
+		}
+		where[condition.field] = *value
+	}
+	return where, ok, nil
+}
+
+func (s *sqlStmt) sort(rows []map[string]string) {
+	if len(s.stmt.orderBy) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, order := range s.stmt.orderBy {
+			a, okA := rows[i][order.field]
+			b, okB := rows[j][order.field]
+			var cmp int
+			switch {
+			case okA == okB:
+				cmp = compareValues(a, b)
+			case okB:
+				cmp = -1
+			default:
+				cmp = 1
+			}
+			if order.desc {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+}
+
+func (s *sqlStmt) limit(values []*string) (int, error) {
+	value := resolveValue(*s.stmt.limit, values)
+	if value == nil {
+		return 0, errors.New("LIMIT must not be NULL")
+	}
+	limit, err := strconv.ParseUint(*value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LIMIT %q", *value)
+	}
+	if limit > math.MaxInt {
+		return math.MaxInt, nil
+	}
+	return int(limit), nil
+}
+
+// resolveValue returns value of literal or placeholder, nil is returned for NULL.
+func resolveValue(value sqlValue, values []*string) *string {
+	if value.arg >= 0 {
+		return values[value.arg]
+	}
+	if value.null {
+		return nil
+	}
+	return &value.text
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// argValues converts arguments to strings, nil is returned for NULL.
+func argValues(args []driver.NamedValue) ([]*string, error) {
+	values := make([]*string, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("named argument %q is not supported", arg.Name)
+		}
+		var value string
+		switch v := arg.Value.(type) {
+		case nil:
+			continue
+		case string:
+			value = v
+		case []byte:
+			value = string(v)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case float64:
+			value = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		case time.Time:
+			value = v.Format(time.RFC3339Nano)
+		default:
+			return nil, fmt.Errorf("unsupported argument type %T", v)
+		}
+		values[i] = &value
+	}
+	return values, nil
+}
+
+type sqlRows struct {
+	columns []string
+	rows    []map[string]string
+}
+
+func (r *sqlRows) Columns() []string {
+	return r.columns
+}
+
+func (r *sqlRows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	for i, column := range r.columns {
+		if value, ok := row[column]; ok {
+			dest[i] = value
+		} else {
+			dest[i] = nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package stable
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func openTestDriverDB(t *testing.T, table string) (*sql.DB, STable) {
+	s, err := NewSTable([]map[string]string{
+		{"id": "1", "name": "Alex", "age": "30", "city": "London"},
+		{"id": "2", "name": "John", "age": "9"},
+		{"id": "3", "name": "Bill", "age": "30", "city": "Paris"},
+	}, "id", []string{"name"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterTable(table, s)
+	t.Cleanup(func() { UnregisterTable(table) })
+	db, err := sql.Open(DriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, s
+}
+
+func TestSQLDriverQuery(t *testing.T) {
+	t.Parallel()
+	db, _ := openTestDriverDB(t, "driver_query")
+	type testTableData struct {
+		testCase        string
+		query           string
+		args            []interface{}
+		expectedColumns []string
+		expectedRows    [][]sql.NullString
+		expectedErr     error
+	}
+	str := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+	testTable := []testTableData{
+		{
+			testCase:        "where and order",
+			query:           "SELECT name, city FROM driver_query WHERE age = ? ORDER BY name DESC",
+			args:            []interface{}{30},
+			expectedColumns: []string{"name", "city"},
+			expectedRows:    [][]sql.NullString{{str("Bill"), str("Paris")}, {str("Alex"), str("London")}},
+			expectedErr:     nil,
+		},
+		{
+			testCase:        "numeric order with limit and absent field",
+			query:           "SELECT id, city FROM driver_query ORDER BY age, id DESC LIMIT ?",
+			args:            []interface{}{2},
+			expectedColumns: []string{"id", "city"},
+			expectedRows:    [][]sql.NullString{{str("2"), {}}, {str("3"), str("Paris")}},
+			expectedErr:     nil,
+		},
+		{
+			testCase:        "all columns",
+			query:           "SELECT * FROM driver_query WHERE id = '2'",
+			args:            nil,
+			expectedColumns: []string{"age", "id", "name"},
+			expectedRows:    [][]sql.NullString{{str("9"), str("2"), str("John")}},
+			expectedErr:     nil,
+		},
+		{
+			testCase:        "contradicting conditions",
+			query:           "SELECT name FROM driver_query WHERE id = 1 AND id = 2",
+			args:            nil,
+			expectedColumns: []string{"name"},
+			expectedRows:    nil,
+			expectedErr:     nil,
+		},
+		{
+			testCase:        "not registered table",
+			query:           "SELECT name FROM driver_unknown",
+			args:            nil,
+			expectedColumns: nil,
+			expectedRows:    nil,
+			expectedErr:     errors.New(`table "driver_unknown" is not registered`),
+		},
+		{
+			testCase:        "NULL in where",
+			query:           "SELECT name FROM driver_query WHERE city = ?",
+			args:            []interface{}{nil},
+			expectedColumns: nil,
+			expectedRows:    nil,
+			expectedErr:     errors.New(`NULL value of column "city" is not supported in WHERE`),
+		},
+	}
+	for _, testUnit := range testTable {
+		rows, err := db.Query(testUnit.query, testUnit.args...)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		if err != nil {
+			continue
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		equal(t, testUnit.expectedColumns, columns, testUnit.testCase)
+		var result [][]sql.NullString
+		for rows.Next() {
+			values := make([]sql.NullString, len(columns))
+			dest := make([]interface{}, len(columns))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			err = rows.Scan(dest...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, values)
+		}
+		equal(t, nil, rows.Err(), testUnit.testCase)
+		equal(t, testUnit.expectedRows, result, testUnit.testCase)
+	}
+}
+
+func TestSQLDriverQueryRow(t *testing.T) {
+	t.Parallel()
+	db, _ := openTestDriverDB(t, "driver_query_row")
+	var age int
+	err := db.QueryRow("SELECT age FROM driver_query_row WHERE id = ?", 1).Scan(&age)
+	equal(t, nil, err, "found error")
+	equal(t, 30, age, "found age")
+
+	err = db.QueryRow("SELECT age FROM driver_query_row WHERE id = ?", 4).Scan(&age)
+	equal(t, sql.ErrNoRows, err, "not found error")
+}
+
+func TestSQLDriverExec(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase         string
+		query            string
+		args             []interface{}
+		expectedAffected int64
+		expectedErr      error
+		expectedSelected []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase:         "insert",
+			query:            "INSERT INTO driver_exec (id, name, city) VALUES (?, ?, NULL), (5, 'Kate', ?)",
+			args:             []interface{}{4, []byte("Mike"), "Rome"},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"id": "1", "name": "Alex", "age": "30", "city": "London"},
+				{"id": "2", "name": "John", "age": "9"},
+				{"id": "3", "name": "Bill", "age": "30", "city": "Paris"},
+				{"id": "4", "name": "Mike"},
+				{"id": "5", "name": "Kate", "city": "Rome"},
+			},
+		},
+		{
+			testCase:         "insert constraints",
+			query:            "INSERT INTO driver_exec (id) VALUES (4)",
+			args:             nil,
+			expectedAffected: 0,
+			expectedErr:      errors.New("empty value for field \"name\""),
+			expectedSelected: []map[string]string{
+				{"id": "1", "name": "Alex", "age": "30", "city": "London"},
+				{"id": "2", "name": "John", "age": "9"},
+				{"id": "3", "name": "Bill", "age": "30", "city": "Paris"},
+			},
+		},
+		{
+			testCase:         "update",
+			query:            "UPDATE driver_exec SET city = ?, age = 31 WHERE age = 30",
+			args:             []interface{}{"Berlin"},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"id": "1", "name": "Alex", "age": "31", "city": "Berlin"},
+				{"id": "2", "name": "John", "age": "9"},
+				{"id": "3", "name": "Bill", "age": "31", "city": "Berlin"},
+			},
+		},
+		{
+			testCase:         "update NULL",
+			query:            "UPDATE driver_exec SET city = NULL",
+			args:             nil,
+			expectedAffected: 0,
+			expectedErr:      errors.New(`NULL value of column "city" is not supported in SET`),
+			expectedSelected: []map[string]string{
+				{"id": "1", "name": "Alex", "age": "30", "city": "London"},
+				{"id": "2", "name": "John", "age": "9"},
+				{"id": "3", "name": "Bill", "age": "30", "city": "Paris"},
+			},
+		},
+		{
+			testCase:         "delete",
+			query:            "DELETE FROM driver_exec WHERE age = ? AND city = ?",
+			args:             []interface{}{30, "Paris"},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"id": "1", "name": "Alex", "age": "30", "city": "London"},
+				{"id": "2", "name": "John", "age": "9"},
+			},
+		},
+		{
+			testCase:         "select as exec",
+			query:            "SELECT * FROM driver_exec",
+			args:             nil,
+			expectedAffected: 0,
+			expectedErr:      errors.New("SELECT must be executed as query"),
+			expectedSelected: []map[string]string{
+				{"id": "1", "name": "Alex", "age": "30", "city": "London"},
+				{"id": "2", "name": "John", "age": "9"},
+				{"id": "3", "name": "Bill", "age": "30", "city": "Paris"},
+			},
+		},
+	}
+	for _, testUnit := range testTable {
+		db, s := openTestDriverDB(t, "driver_exec")
+		var affected int64
+		result, err := db.ExecContext(context.Background(), testUnit.query, testUnit.args...)
+		if err == nil {
+			affected, err = result.RowsAffected()
+		}
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedAffected, affected, testUnit.testCase)
+		equal(t, testUnit.expectedSelected, s.Find(nil), testUnit.testCase)
+		UnregisterTable("driver_exec")
+	}
+}
+
+func TestSQLDriverTransaction(t *testing.T) {
+	t.Parallel()
+	db, _ := openTestDriverDB(t, "driver_transaction")
+	_, err := db.Begin()
+	equal(t, errors.New("transactions are not supported"), err, "begin")
+}
@@ -0,0 +1,379 @@
+package stable
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type sqlStatementKind int
+
+const (
+	sqlSelect sqlStatementKind = iota
+	sqlInsert
+	sqlUpdate
+	sqlDelete
+)
+
+// sqlValue is a literal or a placeholder of SQL statement.
+type sqlValue struct {
+	text string
+	null bool
+	// arg is the index of placeholder argument, it is -1 for literals.
+	arg int
+}
+
+type sqlCondition struct {
+	field string
+	value sqlValue
+}
+
+type sqlAssignment struct {
+	field string
+	value sqlValue
+}
+
+type sqlOrder struct {
+	field string
+	desc  bool
+}
+
+// sqlStatement is a parsed statement of SQL subset supported by the driver.
+type sqlStatement struct {
+	kind  sqlStatementKind
+	table string
+	// columns are selected columns (nil means all columns) or inserted columns.
+	columns []string
+	values  [][]sqlValue
+	set     []sqlAssignment
+	where   []sqlCondition
+	orderBy []sqlOrder
+	limit   *sqlValue
+	// numInput is the number of placeholders.
+	numInput int
+}
+
+// parseSQL parses one of the statements:
+//
+//	SELECT * | column [, ...] FROM table [WHERE column = value [AND ...]] [ORDER BY column [ASC | DESC] [, ...]] [LIMIT count]
+//	INSERT INTO table (column [, ...]) VALUES (value [, ...]) [, ...]
+//	UPDATE table SET column = value [, ...] [WHERE column = value [AND ...]]
+//	DELETE FROM table [WHERE column = value [AND ...]]
+//
+// Values are string or number literals, NULL or ? placeholders.
+func parseSQL(query string) (*sqlStatement, error) {
+	base, err := newParser(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &sqlParser{parser: base}
+	var stmt *sqlStatement
+	switch {
+	case p.acceptKeyword("SELECT"):
+		stmt, err = p.parseSelect()
+	case p.acceptKeyword("INSERT"):
+		stmt, err = p.parseInsert()
+	case p.acceptKeyword("UPDATE"):
+		stmt, err = p.parseUpdate()
+	case p.acceptKeyword("DELETE"):
+		stmt, err = p.parseDelete()
+	default:
+		return nil, p.unexpected("SELECT, INSERT, UPDATE or DELETE")
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.accept(tokenSemicolon)
+	err = p.expectEOF()
+	if err != nil {
+		return nil, err
+	}
+	stmt.numInput = p.numInput
+	return stmt, nil
+}
+
+type sqlParser struct {
+	*parser
+	numInput int
+}
+
+func (p *sqlParser) parseSelect() (*sqlStatement, error) {
+	stmt := &sqlStatement{kind: sqlSelect}
+	if !p.accept(tokenStar) {
+		columns, err := p.parseIdentList()
+		if err != nil {
+			return nil, err
+		}
+		stmt.columns = columns
+	}
+	err := p.expectKeyword("FROM")
+	if err != nil {
+		return nil, err
+	}
+	stmt.table, err = p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt.where, err = p.parseWhere()
+	if err != nil {
+		return nil, err
+	}
+	if p.acceptKeyword("ORDER") {
+		err = p.expectKeyword("BY")
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var order sqlOrder
+			order.field, err = p.expectIdent()
+			if err != nil {
+				return nil, err
+			}
+			if p.acceptKeyword("DESC") {
+				order.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			stmt.orderBy = append(stmt.orderBy, order)
+			if !p.accept(tokenComma) {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.peek()
+		limit, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if limit.null {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "LIMIT must not be NULL"}
+		}
+		if limit.arg < 0 {
+			if _, err := strconv.ParseUint(limit.text, 10, 0); err != nil {
+				return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("invalid LIMIT %v", t)}
+			}
+		}
+		stmt.limit = &limit
+	}
+	return stmt, nil
+}
+
+func (p *sqlParser) parseInsert() (*sqlStatement, error) {
+	stmt := &sqlStatement{kind: sqlInsert}
+	err := p.expectKeyword("INTO")
+	if err != nil {
+		return nil, err
+	}
+	stmt.table, err = p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.expect(tokenLParen, `"("`)
+	if err != nil {
+		return nil, err
+	}
+	stmt.columns, err = p.parseIdentList()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.expect(tokenRParen, `")"`)
+	if err != nil {
+		return nil, err
+	}
+	err = p.expectKeyword("VALUES")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		lparen, err := p.expect(tokenLParen, `"("`)
+		if err != nil {
+			return nil, err
+		}
+		var values []sqlValue
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !p.accept(tokenComma) {
+				break
+			}
+		}
+		_, err = p.expect(tokenRParen, `")"`)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != len(stmt.columns) {
+			return nil, &SyntaxError{
+				Pos: lparen.pos,
+				Msg: fmt.Sprintf("%v values for %v columns", len(values), len(stmt.columns)),
+			}
+		}
+		stmt.values = append(stmt.values, values)
+		if !p.accept(tokenComma) {
+			break
+		}
+	}
+	return stmt, nil
+}
+
+func (p *sqlParser) parseUpdate() (*sqlStatement, error) {
+	stmt := &sqlStatement{kind: sqlUpdate}
+	var err error
+	stmt.table, err = p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	err = p.expectKeyword("SET")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var assignment sqlAssignment
+		assignment.field, err = p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expectOperator("=")
+		if err != nil {
+			return nil, err
+		}
+		assignment.value, err = p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		stmt.set = append(stmt.set, assignment)
+		if !p.accept(tokenComma) {
+			break
+		}
+	}
+	stmt.where, err = p.parseWhere()
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+func (p *sqlParser) parseDelete() (*sqlStatement, error) {
+	stmt := &sqlStatement{kind: sqlDelete}
+	err := p.expectKeyword("FROM")
+	if err != nil {
+		return nil, err
+	}
+	stmt.table, err = p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt.where, err = p.parseWhere()
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// parseWhere parses optional WHERE clause of equality conditions joined by AND.
+func (p *sqlParser) parseWhere() ([]sqlCondition, error) {
+	if !p.acceptKeyword("WHERE") {
+		return nil, nil
+	}
+	var where []sqlCondition
+	for {
+		var condition sqlCondition
+		var err error
+		condition.field, err = p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expectOperator("=")
+		if err != nil {
+			return nil, err
+		}
+		condition.value, err = p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, condition)
+		if !p.acceptKeyword("AND") {
+			return where, nil
+		}
+	}
+}
+
+func (p *sqlParser) parseIdentList() ([]string, error) {
+	var idents []string
+	for {
+		ident, err := p.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		idents = append(idents, ident)
+		if !p.accept(tokenComma) {
+			return idents, nil
+		}
+	}
+}
+
+func (p *sqlParser) parseValue() (sqlValue, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenString || t.kind == tokenNumber:
+		p.advance()
+		return sqlValue{text: t.text, arg: -1}, nil
+	case t.kind == tokenPlaceholder:
+		p.advance()
+		p.numInput++
+		return sqlValue{arg: p.numInput - 1}, nil
+	case p.isKeyword("NULL"):
+		p.advance()
+		return sqlValue{null: true, arg: -1}, nil
+	}
+	return sqlValue{}, p.unexpected("value")
+}
+
+func (p *sqlParser) expectOperator(op string) error {
+	t := p.peek()
+	if t.kind != tokenOperator || t.text != op {
+		return p.unexpected(strconv.Quote(op))
+	}
+	p.advance()
+	return nil
+}
+
+// compareValues compares values as numbers when both are decimal numbers and as strings otherwise.
+func compareValues(a, b string) int {
+	if !isDecimal(a) || !isDecimal(b) {
+		return strings.Compare(a, b)
+	}
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	if errX != nil || errY != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// isDecimal reports whether s is a decimal number of SQL queries: optional minus, digits and at most one point.
+// Other forms accepted by strconv.ParseFloat, like NaN, Inf, exponents and hex, are not numbers.
+func isDecimal(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits, points := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case isDigit(s[i]):
+			digits++
+		case s[i] == '.':
+			points++
+		default:
+			return false
+		}
+	}
+	return digits != 0 && points <= 1
+}
@@ -0,0 +1,147 @@
+package stable
+
+import (
+	"testing"
+)
+
+func TestParseSQL(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase     string
+		query        string
+		expectedStmt *sqlStatement
+		expectedErr  error
+	}
+	testTable := []testTableData{
+		{
+			testCase: "select",
+			query:    "select name, age from customers where city = ? and age = 30 order by age desc, name limit 10",
+			expectedStmt: &sqlStatement{
+				kind:    sqlSelect,
+				table:   "customers",
+				columns: []string{"name", "age"},
+				where: []sqlCondition{
+					{field: "city", value: sqlValue{arg: 0}},
+					{field: "age", value: sqlValue{text: "30", arg: -1}},
+				},
+				orderBy:  []sqlOrder{{field: "age", desc: true}, {field: "name"}},
+				limit:    &sqlValue{text: "10", arg: -1},
+				numInput: 1,
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase: "select all",
+			query:    "SELECT * FROM customers;",
+			expectedStmt: &sqlStatement{
+				kind:  sqlSelect,
+				table: "customers",
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase: "insert",
+			query:    "INSERT INTO customers (id, name) VALUES (?, 'Alex'), (?, NULL)",
+			expectedStmt: &sqlStatement{
+				kind:    sqlInsert,
+				table:   "customers",
+				columns: []string{"id", "name"},
+				values: [][]sqlValue{
+					{{arg: 0}, {text: "Alex", arg: -1}},
+					{{arg: 1}, {null: true, arg: -1}},
+				},
+				numInput: 2,
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase: "update",
+			query:    "UPDATE customers SET name = ?, city = 'Paris' WHERE id = ?",
+			expectedStmt: &sqlStatement{
+				kind:  sqlUpdate,
+				table: "customers",
+				set: []sqlAssignment{
+					{field: "name", value: sqlValue{arg: 0}},
+					{field: "city", value: sqlValue{text: "Paris", arg: -1}},
+				},
+				where:    []sqlCondition{{field: "id", value: sqlValue{arg: 1}}},
+				numInput: 2,
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase: "delete",
+			query:    "DELETE FROM customers",
+			expectedStmt: &sqlStatement{
+				kind:  sqlDelete,
+				table: "customers",
+			},
+			expectedErr: nil,
+		},
+		{
+			testCase:     "unknown statement",
+			query:        "DROP TABLE customers",
+			expectedStmt: nil,
+			expectedErr:  &SyntaxError{Pos: 0, Msg: `expected SELECT, INSERT, UPDATE or DELETE, found "DROP"`},
+		},
+		{
+			testCase:     "unsupported operator",
+			query:        "SELECT * FROM customers WHERE age > 30",
+			expectedStmt: nil,
+			expectedErr:  &SyntaxError{Pos: 34, Msg: `expected "=", found ">"`},
+		},
+		{
+			testCase:     "values count",
+			query:        "INSERT INTO customers (id, name) VALUES (1)",
+			expectedStmt: nil,
+			expectedErr:  &SyntaxError{Pos: 40, Msg: "1 values for 2 columns"},
+		},
+		{
+			testCase:     "invalid limit",
+			query:        "SELECT * FROM customers LIMIT -1",
+			expectedStmt: nil,
+			expectedErr:  &SyntaxError{Pos: 30, Msg: `invalid LIMIT "-1"`},
+		},
+		{
+			testCase:     "trailing tokens",
+			query:        "DELETE FROM customers WHERE id = 1 OR id = 2",
+			expectedStmt: nil,
+			expectedErr:  &SyntaxError{Pos: 35, Msg: `expected end of query, found "OR"`},
+		},
+		{
+			testCase:     "unexpected end",
+			query:        "UPDATE customers SET",
+			expectedStmt: nil,
+			expectedErr:  &SyntaxError{Pos: 20, Msg: "expected identifier, found end of query"},
+		},
+	}
+	for _, testUnit := range testTable {
+		stmt, err := parseSQL(testUnit.query)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedStmt, stmt, testUnit.testCase)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase string
+		a, b     string
+		expected int
+	}
+	testTable := []testTableData{
+		{testCase: "numbers", a: "9", b: "10", expected: -1},
+		{testCase: "equal numbers", a: "1.0", b: "1", expected: 0},
+		{testCase: "strings", a: "9", b: "a", expected: -1},
+		{testCase: "greater string", a: "b", b: "a", expected: 1},
+		{testCase: "negative fraction", a: "-.5", b: "0", expected: -1},
+		{testCase: "NaN is string", a: "NaN", b: "5", expected: 1},
+		{testCase: "infinity is string", a: "-Inf", b: "5", expected: -1},
+		{testCase: "exponent is string", a: "1e3", b: "5", expected: -1},
+		{testCase: "hex is string", a: "0x10", b: "5", expected: -1},
+		{testCase: "two points is string", a: "1.2.3", b: "1.3", expected: -1},
+	}
+	for _, testUnit := range testTable {
+		equal(t, testUnit.expected, compareValues(testUnit.a, testUnit.b), testUnit.testCase)
+	}
+}
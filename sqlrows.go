@@ -0,0 +1,142 @@
+package stable
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FromSQLRows reads all rows of query result as rows for STable.
+// Column names become field names, all values are converted to strings and NULL values become absent fields.
+// It closes rows.
+func FromSQLRows(rows *sql.Rows) ([]map[string]string, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	result := make([]map[string]string, 0)
+	for rows.Next() {
+		err = rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(columns))
+		for i, value := range values {
+			if value.Valid {
+				row[columns[i]] = value.String
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// SQLWriteOptions configures WriteSQL.
+type SQLWriteOptions struct {
+	// Table is the name of SQL table optionally qualified by schema, like "public.customers".
+	// It is inserted into statements as is, so it must consist of letters, digits and underscores.
+	Table string
+	// Columns are fields written as columns of the same names, they are inserted into statements as is
+	// and must consist of letters, digits and underscores not starting with digit.
+	// All fields of written rows are used in sorted order when Columns are empty.
+	Columns []string
+	// Where are conditions of written rows.
+	Where map[string]string
+	// BatchSize is the number of rows in one INSERT statement, 100 is used when it is zero.
+	BatchSize int
+	// Placeholder returns placeholder of n-th argument of a statement starting from 1,
+	// "?" is used when it is nil. Use func(n int) string { return "$" + strconv.Itoa(n) } for PostgreSQL.
+	Placeholder func(n int) string
+}
+
+const defaultSQLBatchSize = 100
+
+var (
+	sqlTableName  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	sqlColumnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// WriteSQL writes rows of STable selected by conditions through tx as batched INSERT statements.
+// Absent fields are written as NULL. It returns the number of written rows.
+func WriteSQL(ctx context.Context, tx *sql.Tx, st STable, opts SQLWriteOptions) (int, error) {
+	if opts.Table == "" {
+		return 0, errors.New("sql table is empty")
+	}
+	if !sqlTableName.MatchString(opts.Table) {
+		return 0, fmt.Errorf("invalid sql table name %q", opts.Table)
+	}
+	rows := st.Find(opts.Where)
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = rowsFields(rows)
+	}
+	for _, column := range columns {
+		if !sqlColumnName.MatchString(column) {
+			return 0, fmt.Errorf("invalid sql column name %q", column)
+		}
+	}
+	if len(columns) == 0 || len(rows) == 0 {
+		return 0, nil
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSQLBatchSize
+	}
+	placeholder := opts.Placeholder
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+	written := 0
+	for len(rows) > 0 {
+		batch := rows
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		query, args := insertStatement(opts.Table, columns, batch, placeholder)
+		_, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return written, err
+		}
+		written += len(batch)
+		rows = rows[len(batch):]
+	}
+	return written, nil
+}
+
+func insertStatement(table string, columns []string, rows []map[string]string, placeholder func(n int) string) (string, []interface{}) {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(table)
+	b.WriteString(" (")
+	b.WriteString(strings.Join(columns, ", "))
+	b.WriteString(") VALUES ")
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j, column := range columns {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(placeholder(len(args) + 1))
+			if value, ok := row[column]; ok {
+				args = append(args, value)
+			} else {
+				args = append(args, nil)
+			}
+		}
+		b.WriteString(")")
+	}
+	return b.String(), args
+}
@@ -0,0 +1,244 @@
+package stable
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestFromSQLRows(t *testing.T) {
+	t.Parallel()
+	db := openTestSQLDB(t, &testSQLData{
+		columns: []string{"id", "name", "age"},
+		values: [][]driver.Value{
+			{int64(1), "Alex", int64(30)},
+			{int64(2), []byte("John"), nil},
+		},
+	})
+	rows, err := db.Query("SELECT id, name, age FROM customers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := FromSQLRows(rows)
+	equal(t, nil, err, "error")
+	equal(t, []map[string]string{
+		{"id": "1", "name": "Alex", "age": "30"},
+		{"id": "2", "name": "John"},
+	}, result, "rows")
+}
+
+func TestWriteSQL(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{
+		{"id": "1", "name": "Alex", "city": "London"},
+		{"id": "2", "name": "John"},
+		{"id": "3", "name": "Bill", "city": "Paris"},
+	}, "id", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type testTableData struct {
+		testCase        string
+		opts            SQLWriteOptions
+		expectedWritten int
+		expectedErr     error
+		expectedExecs   []testSQLExec
+	}
+	testTable := []testTableData{
+		{
+			testCase:        "all fields in batches",
+			opts:            SQLWriteOptions{Table: "customers", BatchSize: 2},
+			expectedWritten: 3,
+			expectedErr:     nil,
+			expectedExecs: []testSQLExec{
+				{
+					query: "INSERT INTO customers (city, id, name) VALUES (?, ?, ?), (?, ?, ?)",
+					args:  []driver.Value{"London", "1", "Alex", nil, "2", "John"},
+				},
+				{
+					query: "INSERT INTO customers (city, id, name) VALUES (?, ?, ?)",
+					args:  []driver.Value{"Paris", "3", "Bill"},
+				},
+			},
+		},
+		{
+			testCase: "selected columns and rows with placeholders",
+			opts: SQLWriteOptions{
+				Table:       "customers",
+				Columns:     []string{"id", "city"},
+				Where:       map[string]string{"name": "Bill"},
+				Placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+			},
+			expectedWritten: 1,
+			expectedErr:     nil,
+			expectedExecs: []testSQLExec{
+				{query: "INSERT INTO customers (id, city) VALUES ($1, $2)", args: []driver.Value{"3", "Paris"}},
+			},
+		},
+		{
+			testCase:        "no rows",
+			opts:            SQLWriteOptions{Table: "customers", Where: map[string]string{"name": "Nobody"}},
+			expectedWritten: 0,
+			expectedErr:     nil,
+			expectedExecs:   nil,
+		},
+		{
+			testCase:        "empty table",
+			opts:            SQLWriteOptions{},
+			expectedWritten: 0,
+			expectedErr:     errors.New("sql table is empty"),
+			expectedExecs:   nil,
+		},
+		{
+			testCase:        "qualified table",
+			opts:            SQLWriteOptions{Table: "public.customers", Columns: []string{"id"}, Where: map[string]string{"id": "1"}},
+			expectedWritten: 1,
+			expectedErr:     nil,
+			expectedExecs: []testSQLExec{
+				{query: "INSERT INTO public.customers (id) VALUES (?)", args: []driver.Value{"1"}},
+			},
+		},
+		{
+			testCase:        "invalid table",
+			opts:            SQLWriteOptions{Table: "customers (id) VALUES (1); DROP TABLE customers; --"},
+			expectedWritten: 0,
+			expectedErr:     errors.New(`invalid sql table name "customers (id) VALUES (1); DROP TABLE customers; --"`),
+			expectedExecs:   nil,
+		},
+		{
+			testCase:        "invalid column",
+			opts:            SQLWriteOptions{Table: "customers", Columns: []string{"id", "name) VALUES (1); --"}},
+			expectedWritten: 0,
+			expectedErr:     errors.New(`invalid sql column name "name) VALUES (1); --"`),
+			expectedExecs:   nil,
+		},
+	}
+	for _, testUnit := range testTable {
+		data := &testSQLData{}
+		db := openTestSQLDB(t, data)
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		written, err := WriteSQL(context.Background(), tx, s, testUnit.opts)
+		equal(t, testUnit.expectedWritten, written, testUnit.testCase)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, nil, tx.Commit(), testUnit.testCase)
+		equal(t, testUnit.expectedExecs, data.execs, testUnit.testCase)
+	}
+}
+
+// testSQLDriver is a fake database/sql driver which returns fixed query results and records executed statements.
+type testSQLDriver struct {
+	mu   sync.Mutex
+	data map[string]*testSQLData
+}
+
+var testDriver = &testSQLDriver{data: make(map[string]*testSQLData)}
+
+func init() {
+	sql.Register("stabletest", testDriver)
+}
+
+type testSQLData struct {
+	columns []string
+	values  [][]driver.Value
+	execs   []testSQLExec
+}
+
+type testSQLExec struct {
+	query string
+	args  []driver.Value
+}
+
+func openTestSQLDB(t *testing.T, data *testSQLData) *sql.DB {
+	testDriver.mu.Lock()
+	testDriver.data[t.Name()] = data
+	testDriver.mu.Unlock()
+	db, err := sql.Open("stabletest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func (d *testSQLDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &testSQLConn{data: d.data[name]}, nil
+}
+
+type testSQLConn struct {
+	data *testSQLData
+}
+
+func (c *testSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &testSQLStmt{conn: c, query: query}, nil
+}
+
+func (c *testSQLConn) Close() error {
+	return nil
+}
+
+func (c *testSQLConn) Begin() (driver.Tx, error) {
+	return c, nil
+}
+
+func (c *testSQLConn) Commit() error {
+	return nil
+}
+
+func (c *testSQLConn) Rollback() error {
+	return nil
+}
+
+type testSQLStmt struct {
+	conn  *testSQLConn
+	query string
+}
+
+func (s *testSQLStmt) Close() error {
+	return nil
+}
+
+func (s *testSQLStmt) NumInput() int {
+	return -1
+}
+
+func (s *testSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.data.execs = append(s.conn.data.execs, testSQLExec{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &testSQLRows{data: s.conn.data}, nil
+}
+
+type testSQLRows struct {
+	data *testSQLData
+	next int
+}
+
+func (r *testSQLRows) Columns() []string {
+	return r.data.columns
+}
+
+func (r *testSQLRows) Close() error {
+	return nil
+}
+
+func (r *testSQLRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data.values) {
+		return io.EOF
+	}
+	copy(dest, r.data.values[r.next])
+	r.next++
+	return nil
+}
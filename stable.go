@@ -1,10 +1,13 @@
 package stable
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // NewSTable creates new STable.
@@ -13,6 +16,7 @@ func NewSTable(
 	primaryKeyField string,
 	nonEmptyFields []string,
 	uniqFields []string,
+	opts ...Option,
 ) (STable, error) {
 	if primaryKeyField == "" {
 		return nil, errors.New("primary key is empty")
@@ -27,38 +31,132 @@ func NewSTable(
 	for _, field := range uniqFields {
 		vs = append(vs, newValueDuplicatesValidator(field))
 	}
-	st := &stable{primaryKeyField: primaryKeyField, validators: vs, triggers: []Trigger{}}
-	return st, st.commit(rows)
+	st := &stable{
+		primaryKeyField: primaryKeyField,
+		nonEmptyFields:  nonEmptyFields,
+		uniqFields:      uniqFields,
+		validators:      vs,
+		stop:            make(chan struct{}),
+		sweepInterval:   defaultSweepInterval,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(st)
+	}
+	err := st.load(rows)
+	if err != nil {
+		return st, err
+	}
+	err = st.replay()
+	if err != nil {
+		return st, err
+	}
+	st.startBackground()
+	st.startSweeper()
+	return st, nil
 }
 
 type stable struct {
 	sync.RWMutex
+	name            string
 	primaryKeyField string
+	nonEmptyFields  []string
+	uniqFields      []string
 	rows            []map[string]string
 	validators      []validator
-	triggers        []Trigger
+	triggers        []*registeredTrigger
+	lastTriggerID   TriggerID
+	subscriptions   []*Subscription
+	seq             uint64
+	changeLog       []Change
+	changeLogSize   int
+	waiters         []*waiter
+	wal             *WAL
+
+	snapshotPath       string
+	checkpointInterval time.Duration
+	checkpointWALSize  int64
+	checkpointMu       sync.Mutex
+	checkpointErrMu    sync.Mutex
+	checkpointErr      error
+	checkpointRequests chan struct{}
+	stop               chan struct{}
+	background         sync.WaitGroup
+	closeOnce          sync.Once
+	closeErr           error
+
+	ttl           time.Duration
+	expiryField   string
+	sweepInterval time.Duration
+	now           func() time.Time
+	meta          map[string]*rowMeta
+	// deleteReasons are reasons of deletes being committed by primary key, ReasonWrite is used for others
+	deleteReasons map[string]ChangeReason
+
+	maxRows  int
+	eviction EvictionPolicy
+	// tick orders inserts and accesses of rows
+	tick atomic.Uint64
+}
+
+func (st *stable) Name() string {
+	return st.name
 }
 
 func (st *stable) Insert(new []map[string]string) (int, error) {
-	st.Lock()
+	return st.InsertContext(context.Background(), new)
+}
+
+func (st *stable) InsertContext(ctx context.Context, new []map[string]string) (int, error) {
+	err := st.lockContext(ctx)
+	if err != nil {
+		return 0, err
+	}
 	defer st.Unlock()
-	return st.insert(new)
+	return st.insert(ctx, new)
 }
 
 func (st *stable) Upsert(new []map[string]string) (int, error) {
-	st.Lock()
+	return st.UpsertContext(context.Background(), new)
+}
+
+func (st *stable) UpsertContext(ctx context.Context, new []map[string]string) (int, error) {
+	err := st.lockContext(ctx)
+	if err != nil {
+		return 0, err
+	}
 	defer st.Unlock()
-	return st.upsert(new)
+	return st.upsert(ctx, new)
 }
 
 func (st *stable) Update(fields map[string]string, where map[string]string) (int, error) {
+	return st.UpdateContext(context.Background(), fields, where)
+}
+
+func (st *stable) UpdateContext(ctx context.Context, fields map[string]string, where map[string]string) (int, error) {
+	err := st.lockContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer st.Unlock()
+	return st.update(ctx, fields, whereCondition(where))
+}
+
+func (st *stable) UpdateWhere(fields map[string]string, cond Condition) (int, error) {
 	st.Lock()
 	defer st.Unlock()
-	return st.update(fields, where)
+	return st.update(context.Background(), fields, cond)
 }
 
 func (st *stable) Select(where map[string]string) ([]map[string]string, error) {
-	st.RLock()
+	return st.SelectContext(context.Background(), where)
+}
+
+func (st *stable) SelectContext(ctx context.Context, where map[string]string) ([]map[string]string, error) {
+	err := st.rLockContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer st.RUnlock()
 	rows := st.selectRows(where)
 	if len(rows) == 0 {
@@ -67,6 +165,16 @@ func (st *stable) Select(where map[string]string) ([]map[string]string, error) {
 	return rows, nil
 }
 
+func (st *stable) SelectWhere(cond Condition) ([]map[string]string, error) {
+	st.RLock()
+	defer st.RUnlock()
+	rows := st.selectMatched(cond)
+	if len(rows) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return rows, nil
+}
+
 func (st *stable) SelectAny(where map[string]string) (map[string]string, error) {
 	st.RLock()
 	defer st.RUnlock()
@@ -77,51 +185,219 @@ func (st *stable) SelectAny(where map[string]string) (map[string]string, error)
 	return row, nil
 }
 
-func (st *stable) AddTrigger(trigger Trigger) {
+func (st *stable) Find(where map[string]string) []map[string]string {
+	st.RLock()
+	defer st.RUnlock()
+	return st.selectRows(where)
+}
+
+func (st *stable) Get(pk string) (map[string]string, bool) {
+	st.RLock()
+	defer st.RUnlock()
+	row := st.getVisible(pk)
+	if row == nil {
+		return nil, false
+	}
+	st.touch(row)
+	return copyRow(row), true
+}
+
+func (st *stable) Query(where map[string]string) *Rows {
+	st.RLock()
+	defer st.RUnlock()
+	// committed rows are copies of written rows never modified in place, so the slice is a consistent snapshot
+	return newRows(st.visibleRows(), where)
+}
+
+func (st *stable) Distinct(field string, where map[string]string) []string {
+	st.RLock()
+	defer st.RUnlock()
+	counts := st.distinctCount(field, where)
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (st *stable) DistinctCount(field string, where map[string]string) map[string]int {
+	st.RLock()
+	defer st.RUnlock()
+	return st.distinctCount(field, where)
+}
+
+func (st *stable) AddTrigger(trigger Trigger) TriggerID {
+	st.Lock()
+	defer st.Unlock()
+	return st.addTrigger(triggerKindRow, trigger, nil)
+}
+
+func (st *stable) AddChangeTrigger(trigger ChangeTrigger) TriggerID {
+	st.Lock()
+	defer st.Unlock()
+	return st.addTrigger(triggerKindChange, trigger, nil)
+}
+
+func (st *stable) AddContextTrigger(trigger ContextTrigger) TriggerID {
+	st.Lock()
+	defer st.Unlock()
+	return st.addTrigger(triggerKindContext, trigger, nil)
+}
+
+func (st *stable) AddFilteredTrigger(trigger Trigger, filter TriggerFilter) TriggerID {
 	st.Lock()
 	defer st.Unlock()
-	st.triggers = append(st.triggers, trigger)
+	filter = filter.copy()
+	return st.addTrigger(triggerKindRow, trigger, &filter)
+}
+
+func (st *stable) AddBeforeTrigger(trigger BeforeTrigger) TriggerID {
+	st.Lock()
+	defer st.Unlock()
+	return st.addTrigger(triggerKindBefore, trigger, nil)
+}
+
+func (st *stable) AddAfterTrigger(trigger AfterTrigger) TriggerID {
+	st.Lock()
+	defer st.Unlock()
+	return st.addTrigger(triggerKindAfter, trigger, nil)
+}
+
+func (st *stable) AddStatementTrigger(trigger StatementTrigger) TriggerID {
+	st.Lock()
+	defer st.Unlock()
+	return st.addTrigger(triggerKindStatement, trigger, nil)
+}
+
+func (st *stable) RemoveTrigger(id TriggerID) bool {
+	st.Lock()
+	defer st.Unlock()
+	for i, trigger := range st.triggers {
+		if trigger.id == id {
+			st.triggers = append(st.triggers[:i:i], st.triggers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (st *stable) EnableTrigger(id TriggerID) bool {
+	st.Lock()
+	defer st.Unlock()
+	return st.setTriggerEnabled(id, true)
+}
+
+func (st *stable) DisableTrigger(id TriggerID) bool {
+	st.Lock()
+	defer st.Unlock()
+	return st.setTriggerEnabled(id, false)
+}
+
+func (st *stable) Triggers() []TriggerInfo {
+	st.RLock()
+	defer st.RUnlock()
+	infos := make([]TriggerInfo, len(st.triggers))
+	for i, trigger := range st.triggers {
+		infos[i] = trigger.info()
+	}
+	return infos
 }
 
 func (st *stable) Delete(where map[string]string) (int, error) {
+	return st.DeleteContext(context.Background(), where)
+}
+
+func (st *stable) DeleteContext(ctx context.Context, where map[string]string) (int, error) {
+	err := st.lockContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer st.Unlock()
+	return st.delete(ctx, whereCondition(where))
+}
+
+func (st *stable) DeleteWhere(cond Condition) (int, error) {
 	st.Lock()
 	defer st.Unlock()
-	return st.delete(where)
+	return st.delete(context.Background(), cond)
 }
 
-func (st *stable) insert(new []map[string]string) (int, error) {
-	err := st.validateRows(new)
+func (st *stable) insert(ctx context.Context, new []map[string]string) (int, error) {
+	affected, err := st.insertRows(ctx, new)
+	return affected, constraintCause(err)
+}
+
+// insertRows is like insert but returns *constraintError with index of the failing new row.
+func (st *stable) insertRows(ctx context.Context, new []map[string]string) (int, error) {
+	err := st.expire(ctx)
 	if err != nil {
 		return 0, err
 	}
 	rows := st.getRowsCopy()
-	rows = append(rows, new...)
-	err = st.commit(rows)
+	for _, row := range new {
+		rows = append(rows, copyRow(row))
+	}
+	rows, err = st.evict(rows, new)
 	if err != nil {
 		return 0, err
 	}
-	return len(new), nil
+	err = st.commit(ctx, rows)
+	if ce, ok := err.(*constraintError); ok {
+		// new rows follow existing ones, evicted rows are never new
+		ce.row -= len(rows) - len(new)
+		if ce.row < 0 {
+			ce.row = -1
+		}
+	}
+	return committed(len(new), err)
+}
+
+func (st *stable) upsert(ctx context.Context, new []map[string]string) (int, error) {
+	affected, err := st.upsertRows(ctx, new)
+	return affected, constraintCause(err)
 }
 
-func (st *stable) upsert(new []map[string]string) (int, error) {
-	err := st.validateRows(new)
+// upsertRows is like upsert but returns *constraintError with index of the failing new row.
+func (st *stable) upsertRows(ctx context.Context, new []map[string]string) (int, error) {
+	// rows with the same primary key would be merged into one, other constraints are checked by commit
+	// after BEFORE triggers
+	i, err := newValueDuplicatesValidator(st.primaryKeyField).invalidRow(new)
+	if err != nil {
+		return 0, &constraintError{row: i, err: err}
+	}
+	err = st.expire(ctx)
 	if err != nil {
 		return 0, err
 	}
 	rows := st.getRowsCopy()
 	rows = st.mergeRows(rows, new)
-	err = st.commit(rows)
+	rows, err = st.evict(rows, new)
 	if err != nil {
 		return 0, err
 	}
-	return len(new), nil
+	err = st.commit(ctx, rows)
+	if ce, ok := err.(*constraintError); ok {
+		ce.row = newRowIndex(new, st.primaryKeyField, rows[ce.row][st.primaryKeyField])
+	}
+	return committed(len(new), err)
+}
+
+// newRowIndex returns index of the first new row with primary key, -1 is returned when there is no such row.
+func newRowIndex(new []map[string]string, primaryKeyField, pk string) int {
+	for i, row := range new {
+		if row[primaryKeyField] == pk {
+			return i
+		}
+	}
+	return -1
 }
 
-func (st *stable) update(fields map[string]string, where map[string]string) (int, error) {
+func (st *stable) update(ctx context.Context, fields map[string]string, cond Condition) (int, error) {
 	if _, ok := fields[st.primaryKeyField]; ok {
 		return 0, errors.New("update of primary key is forbidden")
 	}
-	rows := st.selectRows(where)
+	rows := st.selectMatched(cond)
 	if len(rows) == 0 {
 		return 0, nil
 	}
@@ -130,28 +406,50 @@ func (st *stable) update(fields map[string]string, where map[string]string) (int
 			row[field] = value
 		}
 	}
-	return st.upsert(rows)
+	return st.upsert(ctx, rows)
 }
 
 func (st *stable) selectRows(where map[string]string) []map[string]string {
-	rows := st.getRowsCopy()
 	if len(where) == 0 {
-		return rows
+		return st.selectMatched(nil)
 	}
+	return st.selectMatched(whereCondition(where))
+}
+
+// selectMatched returns copies of rows matched by condition, nil condition matches all rows.
+func (st *stable) selectMatched(cond Condition) []map[string]string {
 	filtered := make([]map[string]string, 0)
-rowsLoop:
-	for _, row := range rows {
-		for field, value := range where {
-			rowValue, ok := row[field]
-			if !ok || rowValue != value {
-				continue rowsLoop
-			}
+	for _, row := range st.visibleRows() {
+		if cond == nil || cond.Match(row) {
+			st.touch(row)
+			filtered = append(filtered, copyRow(row))
 		}
-		filtered = append(filtered, row)
 	}
 	return filtered
 }
 
+func (st *stable) distinctCount(field string, where map[string]string) map[string]int {
+	counts := make(map[string]int)
+	for _, row := range st.visibleRows() {
+		value := row[field]
+		if value == "" || !matches(row, where) {
+			continue
+		}
+		counts[value]++
+	}
+	return counts
+}
+
+func matches(row map[string]string, where map[string]string) bool {
+	for field, value := range where {
+		rowValue, ok := row[field]
+		if !ok || rowValue != value {
+			return false
+		}
+	}
+	return true
+}
+
 func (st *stable) selectAny(where map[string]string) map[string]string {
 	rows := st.selectRows(where)
 	if len(rows) == 0 {
@@ -160,18 +458,19 @@ func (st *stable) selectAny(where map[string]string) map[string]string {
 	return rows[0]
 }
 
-func (st *stable) delete(where map[string]string) (int, error) {
-	rowsForDelete := st.selectRows(where)
+func (st *stable) delete(ctx context.Context, cond Condition) (int, error) {
+	rowsForDelete := st.selectMatched(cond)
 	if len(rowsForDelete) == 0 {
 		return 0, nil
 	}
-	rows := st.getRowsCopy()
-	rows = st.deleteRows(rows, rowsForDelete)
-	err := st.commit(rows)
+	err := st.expire(ctx)
 	if err != nil {
 		return 0, err
 	}
-	return len(rowsForDelete), nil
+	rows := st.getRowsCopy()
+	rows = st.deleteRows(rows, rowsForDelete)
+	err = st.commit(ctx, rows)
+	return committed(len(rowsForDelete), err)
 }
 
 func (st *stable) deleteRows(rows []map[string]string, rowsForDelete []map[string]string) []map[string]string {
@@ -188,27 +487,96 @@ rowsForDeleteLoop:
 	return rows
 }
 
-func (st *stable) commit(rows []map[string]string) error {
-	err := st.validateRows(rows)
+func (st *stable) commit(ctx context.Context, rows []map[string]string) error {
+	defer func() { st.deleteReasons = nil }()
+	err := st.runBeforeTriggers(rows, st.rows)
 	if err != nil {
 		return err
 	}
-	err = st.runTriggers(rows, st.rows)
+	for _, validator := range st.validators {
+		i, err := validator.invalidRow(rows)
+		if err != nil {
+			return &constraintError{row: i, err: err}
+		}
+	}
+	err = st.runTriggers(ctx, rows, st.rows)
 	if err != nil {
 		return err
 	}
+	changes := st.committedChanges(rows, st.rows)
+	err = st.writeAhead(changes)
+	if err != nil {
+		return err
+	}
+	st.requestCheckpoint()
 	st.rows = rows
+	st.seq += uint64(len(changes))
+	st.updateMeta(changes)
+	st.logChanges(changes)
+	st.publish(changes)
+	st.notifyWaiters(changes)
+	return st.runAfterTriggers(changes)
+}
+
+// load sets initial rows with constraints checks.
+// Initial rows are not changes, so they are not passed to triggers and are not numbered.
+// Rows are copied, so caller can not modify committed rows.
+func (st *stable) load(rows []map[string]string) error {
+	err := st.validateRows(rows)
+	if err != nil {
+		return err
+	}
+	if st.maxRows > 0 && len(rows) > st.maxRows {
+		return &TableFullError{MaxRows: st.maxRows}
+	}
+	st.rows = make([]map[string]string, len(rows))
+	for i, row := range rows {
+		st.rows[i] = copyRow(row)
+	}
+	st.resetMeta()
 	return nil
 }
 
+// constraintError is constraints error of write, row is index of the failing row.
+// commit returns it with index of committed rows, insertRows and upsertRows convert it to index of new rows.
+type constraintError struct {
+	row int
+	err error
+}
+
+func (e *constraintError) Error() string {
+	return e.err.Error()
+}
+
+// constraintCause returns constraints error without index of the failing row.
+func constraintCause(err error) error {
+	if ce, ok := err.(*constraintError); ok {
+		return ce.err
+	}
+	return err
+}
+
+// committed returns number of affected rows for error returned by commit.
+// Rows are affected in spite of error when only AFTER trigger failed.
+func committed(affected int, err error) (int, error) {
+	if _, ok := err.(*AfterTriggerError); err != nil && !ok {
+		return 0, err
+	}
+	return affected, err
+}
+
 func (st *stable) getRowsCopy() []map[string]string {
 	cp := make([]map[string]string, len(st.rows))
 	for i, row := range st.rows {
-		cpRow := make(map[string]string, len(row))
-		for field, value := range row {
-			cpRow[field] = value
-		}
-		cp[i] = cpRow
+		cp[i] = copyRow(row)
+	}
+	return cp
+}
+
+func copyRow(row map[string]string) map[string]string {
+	cp := make(map[string]string, len(row))
+	for field, value := range row {
+		cp[field] = value
 	}
 	return cp
 }
@@ -236,74 +604,7 @@ newRowsLoop:
 			}
 			continue newRowsLoop
 		}
-		rows = append(rows, newRow)
+		rows = append(rows, copyRow(newRow))
 	}
 	return rows
 }
-
-func (st *stable) runTriggers(new, old []map[string]string) error {
-	if len(st.triggers) == 0 {
-		return nil
-	}
-	err := st.runInsertUpdateTriggers(new, old)
-	if err != nil {
-		return err
-	}
-	return st.runDeleteTriggers(new, old)
-}
-
-func (st *stable) runInsertUpdateTriggers(new, old []map[string]string) error {
-	for _, newRow := range new {
-		err := st.runTriggersForNewRow(newRow, old)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (st *stable) runTriggersForNewRow(newRow map[string]string, old []map[string]string) error {
-	newPK := newRow[st.primaryKeyField]
-	for _, oldRow := range old {
-		if oldRow[st.primaryKeyField] != newPK {
-			continue // another row
-		}
-		if reflect.DeepEqual(newRow, oldRow) {
-			return nil // not changed
-		}
-		err := st.runTriggersForRow(OperationUpdate, newRow, oldRow)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-	// not found, inserted
-	return st.runTriggersForRow(OperationInsert, newRow, nil)
-}
-
-func (st *stable) runDeleteTriggers(new, old []map[string]string) error {
-oldRowsLoop:
-	for _, oldRow := range old {
-		oldPK := oldRow[st.primaryKeyField]
-		for _, newRow := range new {
-			if newRow[st.primaryKeyField] == oldPK {
-				continue oldRowsLoop
-			}
-		}
-		err := st.runTriggersForRow(OperationDelete, nil, oldRow)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (st *stable) runTriggersForRow(operation int, new, old map[string]string) error {
-	for _, trigger := range st.triggers {
-		err := trigger.Handle(operation, new, old)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
-}
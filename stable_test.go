@@ -236,6 +236,57 @@ func TestSTable_Upsert(t *testing.T) {
 				{"pk": "1", "nonEmpty": "e1", "uniq": "u1"},
 			},
 		},
+		{
+			testCase: "partial update without non-empty field",
+			initRows: []map[string]string{
+				{"pk": "0", "nonEmpty": "e0", "uniq": "u0"},
+			},
+			rows: []map[string]string{
+				{"pk": "0", "new": "n0"},
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedTriggerRecords: []testTriggerRecord{
+				{
+					operation: OperationUpdate,
+					new:       map[string]string{"pk": "0", "nonEmpty": "e0", "uniq": "u0", "new": "n0"},
+					old:       map[string]string{"pk": "0", "nonEmpty": "e0", "uniq": "u0"},
+				},
+			},
+			expectedSelected: []map[string]string{
+				{"pk": "0", "nonEmpty": "e0", "uniq": "u0", "new": "n0"},
+			},
+		},
+		{
+			testCase: "partial update clearing non-empty field",
+			initRows: []map[string]string{
+				{"pk": "0", "nonEmpty": "e0", "uniq": "u0"},
+			},
+			rows: []map[string]string{
+				{"pk": "0", "nonEmpty": ""},
+			},
+			expectedAffected:       0,
+			expectedErr:            errors.New("empty value for field \"nonEmpty\""),
+			expectedTriggerRecords: nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "nonEmpty": "e0", "uniq": "u0"},
+			},
+		},
+		{
+			testCase: "insert without non-empty field",
+			initRows: []map[string]string{
+				{"pk": "0", "nonEmpty": "e0", "uniq": "u0"},
+			},
+			rows: []map[string]string{
+				{"pk": "1", "uniq": "u1"},
+			},
+			expectedAffected:       0,
+			expectedErr:            errors.New("empty value for field \"nonEmpty\""),
+			expectedTriggerRecords: nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "nonEmpty": "e0", "uniq": "u0"},
+			},
+		},
 		{
 			testCase: "trigger error",
 			initRows: []map[string]string{
@@ -519,6 +570,142 @@ func TestSTable_SelectSelectAny(t *testing.T) {
 	}
 }
 
+func TestSTable_FindGet(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "f1": "v0"},
+		{"pk": "1", "f1": "v1"},
+	}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []map[string]string{{"pk": "1", "f1": "v1"}}, s.Find(map[string]string{"f1": "v1"}), "find one")
+	equal(t, []map[string]string{}, s.Find(map[string]string{"f1": "v2"}), "find none")
+	row, ok := s.Get("0")
+	equal(t, map[string]string{"pk": "0", "f1": "v0"}, row, "get existing row")
+	equal(t, true, ok, "get existing row found")
+	row["f1"] = "changed"
+	row, _ = s.Get("0")
+	equal(t, map[string]string{"pk": "0", "f1": "v0"}, row, "get returns copy")
+	row, ok = s.Get("2")
+	equal(t, map[string]string(nil), row, "get not existing row")
+	equal(t, false, ok, "get not existing row found")
+}
+
+func TestSTable_SelectUpdateDeleteWhere(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "region": "eu", "priority": "3"},
+		{"pk": "1", "region": "us", "priority": "10"},
+		{"pk": "2", "region": "asia", "priority": "7"},
+	}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond, err := ParseCondition(`region IN ("eu", "us") OR priority > 5`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := s.SelectWhere(cond)
+	equal(t, nil, err, "select error")
+	equal(t, []map[string]string{
+		{"pk": "0", "region": "eu", "priority": "3"},
+		{"pk": "1", "region": "us", "priority": "10"},
+		{"pk": "2", "region": "asia", "priority": "7"},
+	}, rows, "select")
+
+	cond, err = ParseCondition(`priority >= 7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := s.UpdateWhere(map[string]string{"urgent": "yes"}, cond)
+	equal(t, nil, err, "update error")
+	equal(t, 2, affected, "update affected")
+	affected, err = s.UpdateWhere(map[string]string{"pk": "3"}, cond)
+	equal(t, errors.New("update of primary key is forbidden"), err, "update primary key error")
+	equal(t, 0, affected, "update primary key affected")
+
+	cond, err = ParseCondition(`urgent != "yes"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err = s.SelectWhere(cond)
+	equal(t, sql.ErrNoRows, err, "select absent field error")
+	equal(t, []map[string]string(nil), rows, "select absent field")
+
+	cond, err = ParseCondition(`NOT urgent = "yes"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err = s.DeleteWhere(cond)
+	equal(t, nil, err, "delete error")
+	equal(t, 1, affected, "delete affected")
+	equal(t, []map[string]string{
+		{"pk": "1", "region": "us", "priority": "10", "urgent": "yes"},
+		{"pk": "2", "region": "asia", "priority": "7", "urgent": "yes"},
+	}, s.Find(nil), "rows")
+}
+
+func TestSTable_DistinctDistinctCount(t *testing.T) {
+	t.Parallel()
+	primaryKeyField := "pk"
+	nonEmptyFields := []string{}
+	uniqFields := []string{}
+	initRows := []map[string]string{
+		{"pk": "0", "city": "London", "country": "UK"},
+		{"pk": "1", "city": "New-York", "country": "US"},
+		{"pk": "2", "city": "Boston", "country": "US"},
+		{"pk": "3", "city": "New-York", "country": "US"},
+		{"pk": "4", "city": "", "country": "US"},
+		{"pk": "5", "country": "US"},
+	}
+	type testTableData struct {
+		testCase         string
+		field            string
+		where            map[string]string
+		expectedDistinct []string
+		expectedCount    map[string]int
+	}
+	testTable := []testTableData{
+		{
+			testCase:         "all rows",
+			field:            "city",
+			where:            nil,
+			expectedDistinct: []string{"Boston", "London", "New-York"},
+			expectedCount:    map[string]int{"Boston": 1, "London": 1, "New-York": 2},
+		},
+		{
+			testCase:         "filtered rows",
+			field:            "city",
+			where:            map[string]string{"country": "US"},
+			expectedDistinct: []string{"Boston", "New-York"},
+			expectedCount:    map[string]int{"Boston": 1, "New-York": 2},
+		},
+		{
+			testCase:         "no rows",
+			field:            "city",
+			where:            map[string]string{"country": "FR"},
+			expectedDistinct: []string{},
+			expectedCount:    map[string]int{},
+		},
+		{
+			testCase:         "not existing field",
+			field:            "notExistingField",
+			where:            nil,
+			expectedDistinct: []string{},
+			expectedCount:    map[string]int{},
+		},
+	}
+	s, err := NewSTable(initRows, primaryKeyField, nonEmptyFields, uniqFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, testUnit := range testTable {
+		equal(t, testUnit.expectedDistinct, s.Distinct(testUnit.field, testUnit.where), testUnit.testCase)
+		equal(t, testUnit.expectedCount, s.DistinctCount(testUnit.field, testUnit.where), testUnit.testCase)
+	}
+}
+
 func TestSTable_Delete(t *testing.T) {
 	t.Parallel()
 	primaryKeyField := "pk"
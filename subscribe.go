@@ -0,0 +1,149 @@
+package stable
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// OverflowPolicy defines behaviour of Subscription when its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDrop drops changes which do not fit the buffer.
+	OverflowDrop OverflowPolicy = iota
+	// OverflowBlock blocks the writer until the buffer has room or subscription ctx is done.
+	// The writer holds the lock of STable while it is blocked, so consumer must not call methods of STable
+	// before it receives the change, otherwise it deadlocks.
+	OverflowBlock
+	// OverflowDisconnect closes the subscription with ErrSubscriptionOverflow.
+	OverflowDisconnect
+)
+
+// ErrSubscriptionOverflow is reported by Subscription closed by OverflowDisconnect policy.
+var ErrSubscriptionOverflow = errors.New("subscription buffer overflow")
+
+// Subscription delivers committed changes of STable.
+// Every subscription receives its own copies of changes.
+type Subscription struct {
+	// C delivers changes in order they were committed, it is closed when subscription ends.
+	C <-chan Change
+
+	ctx    context.Context
+	where  map[string]string
+	policy OverflowPolicy
+	ch     chan Change
+	done   chan struct{}
+
+	mu      sync.Mutex
+	err     error
+	dropped uint64
+}
+
+// Err returns the reason subscription ended: ctx error or ErrSubscriptionOverflow.
+// It returns nil while subscription is active.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Dropped returns number of changes dropped by OverflowDrop policy.
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (st *stable) Subscribe(ctx context.Context, where map[string]string, bufSize int, policy OverflowPolicy) *Subscription {
+	ch := make(chan Change, bufSize)
+	sub := &Subscription{
+		C:      ch,
+		ctx:    ctx,
+		where:  copyRow(where),
+		policy: policy,
+		ch:     ch,
+		done:   make(chan struct{}),
+	}
+	st.Lock()
+	st.subscriptions = append(st.subscriptions, sub)
+	st.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			st.Lock()
+			st.unsubscribe(sub, ctx.Err())
+			st.Unlock()
+		case <-sub.done:
+		}
+	}()
+	return sub
+}
+
+// unsubscribe removes subscription and closes its channel.
+// It does nothing for already removed subscription.
+func (st *stable) unsubscribe(sub *Subscription, err error) {
+	for i, s := range st.subscriptions {
+		if s != sub {
+			continue
+		}
+		st.subscriptions = append(st.subscriptions[:i:i], st.subscriptions[i+1:]...)
+		sub.mu.Lock()
+		sub.err = err
+		sub.mu.Unlock()
+		close(sub.ch)
+		close(sub.done)
+		return
+	}
+}
+
+// publish delivers committed changes to subscriptions.
+func (st *stable) publish(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	for _, sub := range append([]*Subscription(nil), st.subscriptions...) {
+		for _, change := range changes {
+			if !sub.matches(change) {
+				continue
+			}
+			if !st.deliver(sub, change.copy()) {
+				break
+			}
+		}
+	}
+}
+
+// deliver sends change to subscription according to its policy.
+// It returns false when subscription should not receive more changes.
+func (st *stable) deliver(sub *Subscription, change Change) bool {
+	if sub.ctx.Err() != nil {
+		return false
+	}
+	select {
+	case sub.ch <- change:
+		return true
+	default:
+	}
+	switch sub.policy {
+	case OverflowBlock:
+		select {
+		case sub.ch <- change:
+			return true
+		case <-sub.ctx.Done():
+			return false
+		}
+	case OverflowDisconnect:
+		st.unsubscribe(sub, ErrSubscriptionOverflow)
+		return false
+	}
+	sub.mu.Lock()
+	sub.dropped++
+	sub.mu.Unlock()
+	return true
+}
+
+func (s *Subscription) matches(change Change) bool {
+	return (change.New != nil && matches(change.New, s.where)) ||
+		(change.Old != nil && matches(change.Old, s.where))
+}
@@ -0,0 +1,140 @@
+package stable
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSTable_Subscribe(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase        string
+		bufSize         int
+		policy          OverflowPolicy
+		expectedChanges []Change
+		expectedErr     error
+		expectedDropped uint64
+	}
+	insert := Change{Operation: OperationInsert, New: map[string]string{"pk": "1", "city": "London"}, ChangedFields: []string{"city", "pk"}, Seq: 1}
+	update := Change{
+		Operation:     OperationUpdate,
+		New:           map[string]string{"pk": "1", "city": "Paris"},
+		Old:           map[string]string{"pk": "1", "city": "London"},
+		ChangedFields: []string{"city"},
+		Seq:           3,
+	}
+	testTable := []testTableData{
+		{
+			testCase:        "buffer fits",
+			bufSize:         2,
+			policy:          OverflowDrop,
+			expectedChanges: []Change{insert, update},
+			expectedErr:     context.Canceled,
+			expectedDropped: 0,
+		},
+		{
+			testCase:        "drop",
+			bufSize:         1,
+			policy:          OverflowDrop,
+			expectedChanges: []Change{insert},
+			expectedErr:     context.Canceled,
+			expectedDropped: 1,
+		},
+		{
+			testCase:        "disconnect",
+			bufSize:         1,
+			policy:          OverflowDisconnect,
+			expectedChanges: []Change{insert},
+			expectedErr:     ErrSubscriptionOverflow,
+			expectedDropped: 0,
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable([]map[string]string{{"pk": "0", "city": "London"}}, "pk", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		sub := s.Subscribe(ctx, map[string]string{"city": "London"}, testUnit.bufSize, testUnit.policy)
+		_, err = s.Insert([]map[string]string{{"pk": "1", "city": "London"}, {"pk": "2", "city": "Berlin"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Update(map[string]string{"city": "Paris"}, map[string]string{"pk": "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		var changes []Change
+		for change := range sub.C {
+			changes = append(changes, change)
+		}
+		equal(t, testUnit.expectedChanges, changes, testUnit.testCase)
+		equal(t, testUnit.expectedErr, sub.Err(), testUnit.testCase)
+		equal(t, testUnit.expectedDropped, sub.Dropped(), testUnit.testCase)
+	}
+}
+
+func TestSTable_SubscribeBlock(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := s.Subscribe(ctx, nil, 0, OverflowBlock)
+	inserted := make(chan error)
+	go func() {
+		_, err := s.Insert([]map[string]string{{"pk": "0"}, {"pk": "1"}})
+		inserted <- err
+	}()
+	select {
+	case <-inserted:
+		t.Fatal("writer is not blocked")
+	case <-time.After(10 * time.Millisecond):
+	}
+	equal(t, map[string]string{"pk": "0"}, (<-sub.C).New, "first change")
+	equal(t, map[string]string{"pk": "1"}, (<-sub.C).New, "second change")
+	equal(t, nil, <-inserted, "writer unblocked")
+}
+
+func TestSTable_SubscribeCancelBlocked(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := s.Subscribe(ctx, nil, 0, OverflowBlock)
+	inserted := make(chan error)
+	go func() {
+		_, err := s.Insert([]map[string]string{{"pk": "0"}})
+		inserted <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	equal(t, nil, <-inserted, "writer unblocked by cancel")
+	_, ok := <-sub.C
+	equal(t, false, ok, "channel closed")
+	equal(t, context.Canceled, sub.Err(), "ctx error")
+}
+
+func TestSTable_SubscribeWhereCopy(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	where := map[string]string{"city": "London"}
+	sub := s.Subscribe(ctx, where, 1, OverflowDrop)
+	where["city"] = "Paris"
+	_, err = s.Insert([]map[string]string{{"pk": "0", "city": "London"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, map[string]string{"pk": "0", "city": "London"}, (<-sub.C).New, "subscription keeps conditions")
+}
@@ -0,0 +1,301 @@
+package stable
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Table is a typed wrapper of STable which maps structs of type T to rows.
+//
+// Exported fields of T become fields of rows, field name and constraints are set by stable tag:
+//
+//	type Customer struct {
+//		ID      int       `stable:"id,pk"`
+//		Phone   string    `stable:"phone,unique,required"`
+//		Created time.Time `stable:"created"`
+//		Note    *string   `stable:"note"`
+//		Cache   []byte    `stable:"-"`
+//	}
+//
+// Name of field without tag or with empty name in tag is the Go field name, "-" skips the field.
+// Options are pk for primary key field (exactly one is required), unique and required
+// for unique and non-empty fields of NewSTable. Only string fields and pointer fields can be required,
+// because fields of other types are never marshalled to empty text.
+//
+// Fields are marshalled with encoding.TextMarshaler and encoding.TextUnmarshaler when the type implements them
+// and with strconv otherwise, so strings, booleans, integers and floats are supported.
+// Nil pointer fields become absent fields and absent fields become nil pointers.
+//
+// Rows are expected to be written through Table, fields of rows which cannot be unmarshalled are left zero.
+type Table[T any] struct {
+	st     STable
+	fields []tableField
+}
+
+type tableField struct {
+	index    int
+	name     string
+	pk       bool
+	unique   bool
+	required bool
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// NewTable creates new Table with STable which primary key and constraints are derived from tags of T.
+func NewTable[T any](values []T, opts ...Option) (*Table[T], error) {
+	fields, err := tableFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+	t := &Table[T]{fields: fields}
+	var primaryKeyField string
+	var nonEmptyFields, uniqFields []string
+	for _, field := range fields {
+		switch {
+		case field.pk:
+			primaryKeyField = field.name
+		case field.unique:
+			uniqFields = append(uniqFields, field.name)
+		}
+		if field.required && !field.pk {
+			nonEmptyFields = append(nonEmptyFields, field.name)
+		}
+	}
+	rows, err := t.rows(values)
+	if err != nil {
+		return nil, err
+	}
+	t.st, err = NewSTable(rows, primaryKeyField, nonEmptyFields, uniqFields, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// STable returns the underlying STable.
+func (t *Table[T]) STable() STable {
+	return t.st
+}
+
+// Insert inserts values with constraints checks.
+func (t *Table[T]) Insert(values ...T) (int, error) {
+	rows, err := t.rows(values)
+	if err != nil {
+		return 0, err
+	}
+	return t.st.Insert(rows)
+}
+
+// Get selects value by marshalled primary key.
+// The second value reports whether the value was found.
+func (t *Table[T]) Get(pk string) (T, bool) {
+	row, ok := t.st.Get(pk)
+	if !ok {
+		var value T
+		return value, false
+	}
+	return t.value(row), true
+}
+
+// Select selects values by conditions on marshalled fields.
+// Empty result is not an error: an empty non-nil slice is returned.
+func (t *Table[T]) Select(where map[string]string) []T {
+	rows := t.st.Find(where)
+	values := make([]T, len(rows))
+	for i, row := range rows {
+		values[i] = t.value(row)
+	}
+	return values
+}
+
+func (t *Table[T]) rows(values []T) ([]map[string]string, error) {
+	rows := make([]map[string]string, len(values))
+	for i, value := range values {
+		v := reflect.ValueOf(&value).Elem()
+		row := make(map[string]string, len(t.fields))
+		for _, field := range t.fields {
+			text, ok, err := marshalField(v.Field(field.index))
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", field.name, err)
+			}
+			if ok {
+				row[field.name] = text
+			}
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+func (t *Table[T]) value(row map[string]string) T {
+	var value T
+	v := reflect.ValueOf(&value).Elem()
+	for _, field := range t.fields {
+		text, ok := row[field.name]
+		if !ok {
+			continue
+		}
+		fieldValue := reflect.New(v.Field(field.index).Type()).Elem()
+		if unmarshalField(fieldValue, text) == nil {
+			v.Field(field.index).Set(fieldValue)
+		}
+	}
+	return value
+}
+
+func tableFields(typ reflect.Type) ([]tableField, error) {
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type %v is not a struct", typ)
+	}
+	var fields []tableField
+	var pk string
+	names := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		tag := structField.Tag.Get("stable")
+		if !structField.IsExported() || tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		field := tableField{index: i, name: options[0]}
+		if field.name == "" {
+			field.name = structField.Name
+		}
+		for _, option := range options[1:] {
+			switch option {
+			case "pk":
+				field.pk = true
+			case "unique":
+				field.unique = true
+			case "required":
+				field.required = true
+			default:
+				return nil, fmt.Errorf("unknown option %q in tag of field %q", option, structField.Name)
+			}
+		}
+		if names[field.name] {
+			return nil, fmt.Errorf("duplicate field name %q", field.name)
+		}
+		names[field.name] = true
+		if field.pk {
+			if pk != "" {
+				return nil, fmt.Errorf("multiple primary key fields %q and %q", pk, field.name)
+			}
+			pk = field.name
+		}
+		err := checkFieldType(structField.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", structField.Name, err)
+		}
+		kind := structField.Type.Kind()
+		if field.required && kind != reflect.String && kind != reflect.Pointer {
+			return nil, fmt.Errorf("field %q: option \"required\" is not supported by type %v", structField.Name, structField.Type)
+		}
+		fields = append(fields, field)
+	}
+	if pk == "" {
+		return nil, fmt.Errorf("type %v has no primary key field", typ)
+	}
+	return fields, nil
+}
+
+func checkFieldType(typ reflect.Type) error {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Implements(textMarshalerType) && reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	}
+	return fmt.Errorf("unsupported type %v", typ)
+}
+
+// marshalField returns text of field, the second value is false for nil pointer.
+func marshalField(v reflect.Value) (string, bool, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err == nil, err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true, nil
+	}
+	return "", false, fmt.Errorf("unsupported type %v", v.Type())
+}
+
+// unmarshalField sets settable v from text.
+func unmarshalField(v reflect.Value, text string) error {
+	if v.Kind() == reflect.Pointer {
+		elem := reflect.New(v.Type().Elem())
+		err := unmarshalField(elem.Elem(), text)
+		if err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("unsupported type %v", v.Type())
+}
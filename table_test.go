@@ -0,0 +1,150 @@
+package stable
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testCustomer struct {
+	ID       int       `stable:"id,pk"`
+	Phone    string    `stable:"phone,unique,required"`
+	Name     string    `stable:",required"`
+	Active   bool      `stable:"active"`
+	Balance  float64   `stable:"balance"`
+	Visits   uint8     `stable:"visits"`
+	Created  time.Time `stable:"created"`
+	Note     *string   `stable:"note"`
+	Cache    []byte    `stable:"-"`
+	internal string
+}
+
+func TestNewTable(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase    string
+		newTable    func() error
+		expectedErr error
+	}
+	testTable := []testTableData{
+		{
+			testCase: "constraints of initial values",
+			newTable: func() error {
+				_, err := NewTable([]testCustomer{{ID: 1, Phone: "1", Name: "Alex"}, {ID: 2, Phone: "1", Name: "John"}})
+				return err
+			},
+			expectedErr: errors.New("duplicate value \"1\" for field \"phone\""),
+		},
+		{
+			testCase: "no primary key",
+			newTable: func() error {
+				_, err := NewTable([]struct{ ID int }{})
+				return err
+			},
+			expectedErr: errors.New("type struct { ID int } has no primary key field"),
+		},
+		{
+			testCase: "multiple primary keys",
+			newTable: func() error {
+				_, err := NewTable([]struct {
+					ID   int    `stable:"id,pk"`
+					Code string `stable:"code,pk"`
+				}{})
+				return err
+			},
+			expectedErr: errors.New("multiple primary key fields \"id\" and \"code\""),
+		},
+		{
+			testCase: "unknown option",
+			newTable: func() error {
+				_, err := NewTable([]struct {
+					ID int `stable:"id,primary"`
+				}{})
+				return err
+			},
+			expectedErr: errors.New("unknown option \"primary\" in tag of field \"ID\""),
+		},
+		{
+			testCase: "unsupported type",
+			newTable: func() error {
+				_, err := NewTable([]struct {
+					ID   int      `stable:"id,pk"`
+					Tags []string `stable:"tags"`
+				}{})
+				return err
+			},
+			expectedErr: errors.New("field \"Tags\": unsupported type []string"),
+		},
+		{
+			testCase: "required non-string",
+			newTable: func() error {
+				_, err := NewTable([]struct {
+					ID     int  `stable:"id,pk"`
+					Visits int  `stable:"visits,required"`
+					Active bool `stable:"active"`
+				}{})
+				return err
+			},
+			expectedErr: errors.New("field \"Visits\": option \"required\" is not supported by type int"),
+		},
+		{
+			testCase: "not a struct",
+			newTable: func() error {
+				_, err := NewTable([]string{})
+				return err
+			},
+			expectedErr: errors.New("type string is not a struct"),
+		},
+	}
+	for _, testUnit := range testTable {
+		equal(t, testUnit.expectedErr, testUnit.newTable(), testUnit.testCase)
+	}
+}
+
+func TestTable(t *testing.T) {
+	t.Parallel()
+	note := "vip"
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	alex := testCustomer{
+		ID: 1, Phone: "+100", Name: "Alex", Active: true, Balance: 10.5, Visits: 3, Created: created, Note: &note,
+	}
+	john := testCustomer{ID: 2, Phone: "+200", Name: "John"}
+	table, err := NewTable([]testCustomer{alex})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	affected, err := table.Insert(john, testCustomer{ID: 3, Phone: "+100", Name: "Bill"})
+	equal(t, errors.New("duplicate value \"+100\" for field \"phone\""), err, "insert duplicate error")
+	equal(t, 0, affected, "insert duplicate affected")
+	affected, err = table.Insert(testCustomer{ID: 3, Phone: "+300"})
+	equal(t, errors.New("empty value for field \"Name\""), err, "insert empty error")
+	equal(t, 0, affected, "insert empty affected")
+	affected, err = table.Insert(john)
+	equal(t, nil, err, "insert error")
+	equal(t, 1, affected, "insert affected")
+
+	row, ok := table.STable().Get("1")
+	equal(t, map[string]string{
+		"id": "1", "phone": "+100", "Name": "Alex", "active": "true", "balance": "10.5", "visits": "3",
+		"created": "2020-01-02T03:04:05Z", "note": "vip",
+	}, row, "marshalled row")
+	equal(t, true, ok, "marshalled row found")
+
+	value, ok := table.Get("1")
+	equal(t, alex, value, "get")
+	equal(t, true, ok, "get found")
+	value, ok = table.Get("3")
+	equal(t, testCustomer{}, value, "get not found")
+	equal(t, false, ok, "get not found found")
+
+	equal(t, []testCustomer{john}, table.Select(map[string]string{"active": "false"}), "select")
+	equal(t, []testCustomer{}, table.Select(map[string]string{"phone": "+300"}), "select none")
+
+	_, err = table.STable().Update(map[string]string{"visits": "many"}, map[string]string{"id": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, _ = table.Get("2")
+	equal(t, john, value, "field which cannot be unmarshalled")
+}
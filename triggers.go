@@ -0,0 +1,383 @@
+package stable
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+// AfterTriggerError is returned when AFTER trigger failed.
+// Changes of the operation are committed in spite of the error.
+type AfterTriggerError struct {
+	Err error
+}
+
+func (e *AfterTriggerError) Error() string {
+	return "after trigger: " + e.Err.Error()
+}
+
+// Unwrap returns the error returned by AFTER trigger.
+func (e *AfterTriggerError) Unwrap() error {
+	return e.Err
+}
+
+type triggerKind int
+
+const (
+	triggerKindRow triggerKind = iota
+	triggerKindChange
+	triggerKindContext
+	triggerKindBefore
+	triggerKindAfter
+	triggerKindStatement
+)
+
+type registeredTrigger struct {
+	id      TriggerID
+	kind    triggerKind
+	handler interface{}
+	filter  *TriggerFilter
+	enabled bool
+}
+
+func (rt *registeredTrigger) info() TriggerInfo {
+	info := TriggerInfo{ID: rt.id, Trigger: rt.handler, Enabled: rt.enabled}
+	if rt.filter != nil {
+		filter := rt.filter.copy()
+		info.Filter = &filter
+	}
+	return info
+}
+
+// matches reports whether trigger should be called for the change.
+func (rt *registeredTrigger) matches(change rowChange) bool {
+	if rt.filter == nil {
+		return true
+	}
+	return rt.filter.matches(change)
+}
+
+// copy returns deep copy of filter, so it can not be changed by caller while triggers are running.
+func (f *TriggerFilter) copy() TriggerFilter {
+	var cp TriggerFilter
+	if f.Operations != nil {
+		cp.Operations = append([]Operation{}, f.Operations...)
+	}
+	if f.Fields != nil {
+		cp.Fields = append([]string{}, f.Fields...)
+	}
+	if f.Where != nil {
+		cp.Where = copyRow(f.Where)
+	}
+	return cp
+}
+
+func (f *TriggerFilter) matches(change rowChange) bool {
+	if len(f.Operations) != 0 && !containsOperation(f.Operations, change.operation) {
+		return false
+	}
+	if len(f.Fields) != 0 && change.operation == OperationUpdate && !anyFieldChanged(f.Fields, change.new, change.old) {
+		return false
+	}
+	row := change.new
+	if change.operation == OperationDelete {
+		row = change.old
+	}
+	return matches(row, f.Where)
+}
+
+func containsOperation(operations []Operation, operation Operation) bool {
+	for _, op := range operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func anyFieldChanged(fields []string, new, old map[string]string) bool {
+	for _, field := range fields {
+		newValue, newOk := new[field]
+		oldValue, oldOk := old[field]
+		if newOk != oldOk || newValue != oldValue {
+			return true
+		}
+	}
+	return false
+}
+
+func (st *stable) addTrigger(kind triggerKind, handler interface{}, filter *TriggerFilter) TriggerID {
+	st.lastTriggerID++
+	st.triggers = append(st.triggers, &registeredTrigger{
+		id:      st.lastTriggerID,
+		kind:    kind,
+		handler: handler,
+		filter:  filter,
+		enabled: true,
+	})
+	return st.lastTriggerID
+}
+
+func (st *stable) setTriggerEnabled(id TriggerID, enabled bool) bool {
+	for _, trigger := range st.triggers {
+		if trigger.id == id {
+			trigger.enabled = enabled
+			return true
+		}
+	}
+	return false
+}
+
+// enabledTriggers returns enabled triggers of kinds in order they were added.
+func (st *stable) enabledTriggers(kinds ...triggerKind) []*registeredTrigger {
+	var triggers []*registeredTrigger
+	for _, trigger := range st.triggers {
+		if trigger.enabled && containsKind(kinds, trigger.kind) {
+			triggers = append(triggers, trigger)
+		}
+	}
+	return triggers
+}
+
+func containsKind(kinds []triggerKind, kind triggerKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// rowChange represents a change of one row between two states of STable.
+type rowChange struct {
+	operation Operation
+	new, old  map[string]string
+	// index of new row in new state, -1 for deleted rows
+	index int
+}
+
+// diff compares new state of STable with old one.
+// Inserted and updated rows are returned in order of new state, deleted rows follow in order of old state.
+func (st *stable) diff(new, old []map[string]string) []rowChange {
+	oldRows := make(map[string]map[string]string, len(old))
+	for _, oldRow := range old {
+		oldRows[oldRow[st.primaryKeyField]] = oldRow
+	}
+	newPKs := make(map[string]struct{}, len(new))
+	changes := make([]rowChange, 0)
+	for i, newRow := range new {
+		newPK := newRow[st.primaryKeyField]
+		oldRow, ok := oldRows[newPK]
+		_, seen := newPKs[newPK]
+		newPKs[newPK] = struct{}{}
+		switch {
+		case !ok || seen:
+			// not found or duplicated, inserted
+			changes = append(changes, rowChange{operation: OperationInsert, new: newRow, index: i})
+		case !reflect.DeepEqual(newRow, oldRow):
+			changes = append(changes, rowChange{operation: OperationUpdate, new: newRow, old: oldRow, index: i})
+		}
+	}
+	for _, oldRow := range old {
+		if _, ok := newPKs[oldRow[st.primaryKeyField]]; ok {
+			continue
+		}
+		changes = append(changes, rowChange{operation: OperationDelete, old: oldRow, index: -1})
+	}
+	return changes
+}
+
+func (st *stable) runBeforeTriggers(new, old []map[string]string) error {
+	triggers := st.enabledTriggers(triggerKindBefore)
+	if len(triggers) == 0 {
+		return nil
+	}
+	for _, change := range st.diff(new, old) {
+		row, err := st.runBeforeTriggersForRow(triggers, change)
+		if err != nil {
+			return err
+		}
+		if change.index >= 0 {
+			new[change.index] = row
+		}
+	}
+	return nil
+}
+
+func (st *stable) runBeforeTriggersForRow(triggers []*registeredTrigger, change rowChange) (map[string]string, error) {
+	row := change.new
+	for _, trigger := range triggers {
+		var in, old map[string]string
+		if row != nil {
+			in = copyRow(row)
+		}
+		if change.old != nil {
+			old = copyRow(change.old)
+		}
+		out, err := trigger.handler.(BeforeTrigger).HandleBefore(change.operation, in, old)
+		if err != nil && st.systemDelete(change) {
+			// the delete can not be rejected, the rest of triggers are called for it
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out == nil || change.operation == OperationDelete {
+			continue
+		}
+		if change.operation == OperationUpdate && out[st.primaryKeyField] != change.old[st.primaryKeyField] {
+			return nil, errors.New("update of primary key is forbidden")
+		}
+		// trigger may keep returned row, it is copied to keep committed row unchanged
+		row = copyRow(out)
+	}
+	return row, nil
+}
+
+func (st *stable) runTriggers(ctx context.Context, new, old []map[string]string) error {
+	triggers := st.enabledTriggers(triggerKindRow, triggerKindChange, triggerKindContext)
+	if len(triggers) == 0 {
+		return nil
+	}
+	for _, change := range st.diff(new, old) {
+		err := st.runTriggersForRow(ctx, triggers, change)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *stable) runTriggersForRow(ctx context.Context, triggers []*registeredTrigger, change rowChange) error {
+	for _, trigger := range triggers {
+		if !trigger.matches(change) {
+			continue
+		}
+		var err error
+		switch trigger.kind {
+		case triggerKindRow:
+			err = trigger.handler.(Trigger).Handle(int(change.operation), copyOptionalRow(change.new), copyOptionalRow(change.old))
+		case triggerKindChange:
+			err = trigger.handler.(ChangeTrigger).HandleChange(st.export(change))
+		case triggerKindContext:
+			err = trigger.handler.(ContextTrigger).HandleContext(ctx, st.export(change))
+		}
+		if err != nil && !st.systemDelete(change) {
+			return err
+		}
+	}
+	return nil
+}
+
+// systemDelete reports whether change is delete made by STable itself, triggers can not reject it.
+func (st *stable) systemDelete(change rowChange) bool {
+	return change.operation == OperationDelete && st.deleteReasons[change.old[st.primaryKeyField]] != ReasonWrite
+}
+
+// committedChanges returns changes between new state to be committed and old one
+// numbered with sequence numbers following the last committed change.
+func (st *stable) committedChanges(new, old []map[string]string) []Change {
+	diff := st.diff(new, old)
+	if len(diff) == 0 {
+		return nil
+	}
+	changes := make([]Change, len(diff))
+	for i, change := range diff {
+		changes[i] = st.export(change)
+		changes[i].Seq = st.seq + uint64(i) + 1
+	}
+	return changes
+}
+
+// runAfterTriggers runs AFTER and statement triggers in order they were added.
+func (st *stable) runAfterTriggers(changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	triggers := st.enabledTriggers(triggerKindAfter, triggerKindStatement)
+	for _, trigger := range triggers {
+		var err error
+		switch trigger.kind {
+		case triggerKindAfter:
+			err = trigger.handler.(AfterTrigger).HandleAfter(copyChanges(changes))
+		case triggerKindStatement:
+			err = trigger.handler.(StatementTrigger).HandleStatement(*newChangeSet(copyChanges(changes)))
+		}
+		if err != nil {
+			return &AfterTriggerError{Err: err}
+		}
+	}
+	return nil
+}
+
+func newChangeSet(changes []Change) *ChangeSet {
+	changeSet := &ChangeSet{}
+	for _, change := range changes {
+		switch change.Operation {
+		case OperationInsert:
+			changeSet.Inserted = append(changeSet.Inserted, change.New)
+		case OperationUpdate:
+			changeSet.Updated = append(changeSet.Updated, RowUpdate{New: change.New, Old: change.Old})
+		case OperationDelete:
+			changeSet.Deleted = append(changeSet.Deleted, change.Old)
+		}
+	}
+	return changeSet
+}
+
+// export returns Change with copies of rows.
+func (st *stable) export(c rowChange) Change {
+	change := Change{
+		Operation:     c.operation,
+		ChangedFields: changedFields(c.new, c.old),
+		Table:         st.name,
+	}
+	if c.operation == OperationDelete {
+		change.Reason = st.deleteReasons[c.old[st.primaryKeyField]]
+	}
+	change.New = copyOptionalRow(c.new)
+	change.Old = copyOptionalRow(c.old)
+	return change
+}
+
+// copyOptionalRow returns copy of row, nil is returned for nil row.
+func copyOptionalRow(row map[string]string) map[string]string {
+	if row == nil {
+		return nil
+	}
+	return copyRow(row)
+}
+
+// copy returns Change with copies of rows, so every consumer of committed changes gets its own one.
+func (c Change) copy() Change {
+	c.New = copyOptionalRow(c.New)
+	c.Old = copyOptionalRow(c.Old)
+	c.ChangedFields = append([]string{}, c.ChangedFields...)
+	return c
+}
+
+func copyChanges(changes []Change) []Change {
+	cp := make([]Change, len(changes))
+	for i, change := range changes {
+		cp[i] = change.copy()
+	}
+	return cp
+}
+
+// changedFields returns sorted names of fields which are different in new and old rows.
+func changedFields(new, old map[string]string) []string {
+	fields := make([]string, 0)
+	for field, value := range new {
+		if oldValue, ok := old[field]; !ok || oldValue != value {
+			fields = append(fields, field)
+		}
+	}
+	for field := range old {
+		if _, ok := new[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,564 @@
+package stable
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSTable_BeforeTrigger(t *testing.T) {
+	t.Parallel()
+	primaryKeyField := "pk"
+	nonEmptyFields := []string{"phone"}
+	uniqFields := []string{"phone"}
+	initRows := []map[string]string{
+		{"pk": "0", "phone": "112233"},
+		{"pk": "1", "phone": "223344"},
+	}
+	type testTableData struct {
+		testCase         string
+		trigger          BeforeTrigger
+		run              func(s STable) (int, error)
+		expectedAffected int
+		expectedErr      error
+		expectedSelected []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase: "modify inserted row",
+			trigger:  testBeforeTriggerFunc(normalizePhone),
+			run: func(s STable) (int, error) {
+				return s.Insert([]map[string]string{{"pk": "2", "phone": "33-44-55"}})
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+				{"pk": "2", "phone": "334455"},
+			},
+		},
+		{
+			testCase: "modify updated row",
+			trigger:  testBeforeTriggerFunc(normalizePhone),
+			run: func(s STable) (int, error) {
+				return s.Update(map[string]string{"phone": "44-55-66"}, map[string]string{"pk": "1"})
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "445566"},
+			},
+		},
+		{
+			testCase: "modified row is validated",
+			trigger:  testBeforeTriggerFunc(normalizePhone),
+			run: func(s STable) (int, error) {
+				return s.Insert([]map[string]string{{"pk": "2", "phone": "11-22-33"}})
+			},
+			expectedAffected: 0,
+			expectedErr:      errors.New("duplicate value \"112233\" for field \"phone\""),
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+			},
+		},
+		{
+			testCase: "fill non-empty field of inserted row",
+			trigger:  testBeforeTriggerFunc(fillPhone),
+			run: func(s STable) (int, error) {
+				return s.Insert([]map[string]string{{"pk": "2"}})
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+				{"pk": "2", "phone": "000002"},
+			},
+		},
+		{
+			testCase: "fill non-empty field of upserted row",
+			trigger:  testBeforeTriggerFunc(fillPhone),
+			run: func(s STable) (int, error) {
+				return s.Upsert([]map[string]string{{"pk": "3"}})
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+				{"pk": "3", "phone": "000003"},
+			},
+		},
+		{
+			testCase: "upserted rows with the same primary key",
+			trigger:  testBeforeTriggerFunc(fillPhone),
+			run: func(s STable) (int, error) {
+				return s.Upsert([]map[string]string{{"pk": "3"}, {"pk": "3"}})
+			},
+			expectedAffected: 0,
+			expectedErr:      errors.New("duplicate value \"3\" for field \"pk\""),
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+			},
+		},
+		{
+			testCase: "veto delete",
+			trigger: testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+				if operation == OperationDelete {
+					return nil, errors.New("delete is forbidden")
+				}
+				return new, nil
+			}),
+			run: func(s STable) (int, error) {
+				return s.Delete(map[string]string{"pk": "1"})
+			},
+			expectedAffected: 0,
+			expectedErr:      errors.New("delete is forbidden"),
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+			},
+		},
+		{
+			testCase: "update of primary key",
+			trigger: testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+				new["pk"] = "3"
+				return new, nil
+			}),
+			run: func(s STable) (int, error) {
+				return s.Update(map[string]string{"phone": "445566"}, map[string]string{"pk": "1"})
+			},
+			expectedAffected: 0,
+			expectedErr:      errors.New("update of primary key is forbidden"),
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "223344"},
+			},
+		},
+		{
+			testCase: "changes of received rows are not leaked",
+			trigger: testBeforeTriggerFunc(func(operation Operation, new, old map[string]string) (map[string]string, error) {
+				new["leaked"] = "true"
+				old["leaked"] = "true"
+				return nil, nil
+			}),
+			run: func(s STable) (int, error) {
+				return s.Update(map[string]string{"phone": "445566"}, map[string]string{"pk": "1"})
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "phone": "112233"},
+				{"pk": "1", "phone": "445566"},
+			},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable(initRows, primaryKeyField, nonEmptyFields, uniqFields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AddBeforeTrigger(testUnit.trigger)
+		affected, err := testUnit.run(s)
+		equal(t, testUnit.expectedAffected, affected, testUnit.testCase)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedSelected, s.Find(nil), testUnit.testCase)
+	}
+}
+
+type testBeforeTriggerFunc func(operation Operation, new, old map[string]string) (map[string]string, error)
+
+func (f testBeforeTriggerFunc) HandleBefore(operation Operation, new, old map[string]string) (map[string]string, error) {
+	return f(operation, new, old)
+}
+
+func normalizePhone(operation Operation, new, old map[string]string) (map[string]string, error) {
+	if operation != OperationDelete {
+		new["phone"] = strings.Replace(new["phone"], "-", "", -1)
+	}
+	return new, nil
+}
+
+func fillPhone(operation Operation, new, old map[string]string) (map[string]string, error) {
+	if operation == OperationInsert && new["phone"] == "" {
+		new["phone"] = "00000" + new["pk"]
+	}
+	return new, nil
+}
+
+func TestSTable_AfterTrigger(t *testing.T) {
+	t.Parallel()
+	primaryKeyField := "pk"
+	initRows := []map[string]string{
+		{"pk": "0", "f1": "v0"},
+		{"pk": "1", "f1": "v1"},
+	}
+	type testTableData struct {
+		testCase         string
+		afterErr         error
+		run              func(s STable) (int, error)
+		expectedAffected int
+		expectedErr      error
+		expectedChanges  [][]Change
+		expectedSelected []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase: "upsert",
+			run: func(s STable) (int, error) {
+				return s.Upsert([]map[string]string{{"pk": "1", "f1": "v11"}, {"pk": "2", "f1": "v2"}})
+			},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedChanges: [][]Change{{
+				{Operation: OperationUpdate, New: map[string]string{"pk": "1", "f1": "v11"}, Old: map[string]string{"pk": "1", "f1": "v1"}, ChangedFields: []string{"f1"}, Seq: 1},
+				{Operation: OperationInsert, New: map[string]string{"pk": "2", "f1": "v2"}, ChangedFields: []string{"f1", "pk"}, Seq: 2},
+			}},
+			expectedSelected: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+				{"pk": "1", "f1": "v11"},
+				{"pk": "2", "f1": "v2"},
+			},
+		},
+		{
+			testCase: "delete",
+			run: func(s STable) (int, error) {
+				return s.Delete(nil)
+			},
+			expectedAffected: 2,
+			expectedErr:      nil,
+			expectedChanges: [][]Change{{
+				{Operation: OperationDelete, Old: map[string]string{"pk": "0", "f1": "v0"}, ChangedFields: []string{"f1", "pk"}, Seq: 1},
+				{Operation: OperationDelete, Old: map[string]string{"pk": "1", "f1": "v1"}, ChangedFields: []string{"f1", "pk"}, Seq: 2},
+			}},
+			expectedSelected: []map[string]string{},
+		},
+		{
+			testCase: "no changes",
+			run: func(s STable) (int, error) {
+				return s.Update(map[string]string{"f1": "v0"}, map[string]string{"pk": "0"})
+			},
+			expectedAffected: 1,
+			expectedErr:      nil,
+			expectedChanges:  nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+				{"pk": "1", "f1": "v1"},
+			},
+		},
+		{
+			testCase: "rejected operation",
+			run: func(s STable) (int, error) {
+				return s.Insert([]map[string]string{{"pk": "triggerError"}})
+			},
+			expectedAffected: 0,
+			expectedErr:      errors.New("trigger error"),
+			expectedChanges:  nil,
+			expectedSelected: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+				{"pk": "1", "f1": "v1"},
+			},
+		},
+		{
+			testCase: "after trigger error",
+			afterErr: errors.New("after error"),
+			run: func(s STable) (int, error) {
+				return s.Insert([]map[string]string{{"pk": "2"}})
+			},
+			expectedAffected: 1,
+			expectedErr:      &AfterTriggerError{Err: errors.New("after error")},
+			expectedChanges: [][]Change{{
+				{Operation: OperationInsert, New: map[string]string{"pk": "2"}, ChangedFields: []string{"pk"}, Seq: 1},
+			}},
+			expectedSelected: []map[string]string{
+				{"pk": "0", "f1": "v0"},
+				{"pk": "1", "f1": "v1"},
+				{"pk": "2"},
+			},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable(initRows, primaryKeyField, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AddTrigger(newTestTrigger(primaryKeyField, "triggerError"))
+		first := &testAfterTrigger{err: testUnit.afterErr}
+		second := &testAfterTrigger{}
+		s.AddAfterTrigger(first)
+		s.AddAfterTrigger(second)
+		affected, err := testUnit.run(s)
+		equal(t, testUnit.expectedAffected, affected, testUnit.testCase)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedChanges, first.changes, testUnit.testCase)
+		if testUnit.afterErr == nil {
+			equal(t, testUnit.expectedChanges, second.changes, testUnit.testCase)
+		} else {
+			equal(t, [][]Change(nil), second.changes, testUnit.testCase)
+		}
+		equal(t, testUnit.expectedSelected, s.Find(nil), testUnit.testCase)
+	}
+}
+
+type testAfterTrigger struct {
+	changes [][]Change
+	err     error
+}
+
+func (tt *testAfterTrigger) HandleAfter(changes []Change) error {
+	tt.changes = append(tt.changes, changes)
+	return tt.err
+}
+
+func TestSTable_TriggerManagement(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := newTestTrigger("pk", "triggerError")
+	second := &testAfterTrigger{}
+	firstID := s.AddTrigger(first)
+	secondID := s.AddAfterTrigger(second)
+	equal(t, []TriggerInfo{
+		{ID: firstID, Trigger: first, Enabled: true},
+		{ID: secondID, Trigger: second, Enabled: true},
+	}, s.Triggers(), "triggers added")
+
+	equal(t, true, s.DisableTrigger(firstID), "disable existing trigger")
+	_, err = s.Insert([]map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []testTriggerRecord(nil), first.getRecords(), "disabled trigger not called")
+	equal(t, 1, len(second.changes), "enabled trigger called")
+
+	equal(t, true, s.EnableTrigger(firstID), "enable existing trigger")
+	equal(t, true, s.RemoveTrigger(secondID), "remove existing trigger")
+	_, err = s.Insert([]map[string]string{{"pk": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []testTriggerRecord{{operation: OperationInsert, new: map[string]string{"pk": "1"}}}, first.getRecords(), "enabled trigger called")
+	equal(t, 1, len(second.changes), "removed trigger not called")
+	equal(t, []TriggerInfo{{ID: firstID, Trigger: first, Enabled: true}}, s.Triggers(), "trigger removed")
+
+	equal(t, false, s.RemoveTrigger(secondID), "remove not existing trigger")
+	equal(t, false, s.EnableTrigger(secondID), "enable not existing trigger")
+	equal(t, false, s.DisableTrigger(secondID), "disable not existing trigger")
+}
+
+func TestSTable_TriggersFilterCopy(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger := newTestTrigger("pk", "triggerError")
+	filter := TriggerFilter{Operations: []Operation{OperationInsert}, Where: map[string]string{"f1": "v1"}}
+	id := s.AddFilteredTrigger(trigger, filter)
+	filter.Operations[0] = OperationDelete
+	filter.Where["f1"] = "changed"
+	info := s.Triggers()[0]
+	info.Filter.Operations[0] = OperationDelete
+	info.Filter.Where["f1"] = "changed"
+	equal(t, []TriggerInfo{{
+		ID:      id,
+		Trigger: trigger,
+		Filter:  &TriggerFilter{Operations: []Operation{OperationInsert}, Where: map[string]string{"f1": "v1"}},
+		Enabled: true,
+	}}, s.Triggers(), "filter is not shared")
+	_, err = s.Insert([]map[string]string{{"pk": "0", "f1": "v1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, 1, len(trigger.getRecords()), "trigger called by original filter")
+}
+
+func TestSTable_FilteredTrigger(t *testing.T) {
+	t.Parallel()
+	primaryKeyField := "pk"
+	initRows := []map[string]string{
+		{"pk": "0", "status": "new", "region": "eu"},
+		{"pk": "1", "status": "new", "region": "us"},
+	}
+	insert := testTriggerRecord{
+		operation: OperationInsert,
+		new:       map[string]string{"pk": "2", "status": "new", "region": "eu"},
+	}
+	updateStatus := testTriggerRecord{
+		operation: OperationUpdate,
+		new:       map[string]string{"pk": "0", "status": "done", "region": "eu"},
+		old:       map[string]string{"pk": "0", "status": "new", "region": "eu"},
+	}
+	updateRegion := testTriggerRecord{
+		operation: OperationUpdate,
+		new:       map[string]string{"pk": "1", "status": "new", "region": "eu"},
+		old:       map[string]string{"pk": "1", "status": "new", "region": "us"},
+	}
+	deleteRow := testTriggerRecord{
+		operation: OperationDelete,
+		old:       map[string]string{"pk": "1", "status": "new", "region": "eu"},
+	}
+	type testTableData struct {
+		testCase        string
+		filter          TriggerFilter
+		expectedRecords []testTriggerRecord
+	}
+	testTable := []testTableData{
+		{
+			testCase:        "empty filter",
+			filter:          TriggerFilter{},
+			expectedRecords: []testTriggerRecord{insert, updateStatus, updateRegion, deleteRow},
+		},
+		{
+			testCase:        "by operations",
+			filter:          TriggerFilter{Operations: []Operation{OperationInsert, OperationDelete}},
+			expectedRecords: []testTriggerRecord{insert, deleteRow},
+		},
+		{
+			testCase:        "by changed fields",
+			filter:          TriggerFilter{Fields: []string{"status"}},
+			expectedRecords: []testTriggerRecord{insert, updateStatus, deleteRow},
+		},
+		{
+			testCase:        "by where",
+			filter:          TriggerFilter{Where: map[string]string{"region": "eu"}},
+			expectedRecords: []testTriggerRecord{insert, updateStatus, updateRegion, deleteRow},
+		},
+		{
+			testCase:        "by all",
+			filter:          TriggerFilter{Operations: []Operation{OperationUpdate}, Fields: []string{"status"}, Where: map[string]string{"status": "done"}},
+			expectedRecords: []testTriggerRecord{updateStatus},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable(initRows, primaryKeyField, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trigger := newTestTrigger(primaryKeyField, "triggerError")
+		s.AddFilteredTrigger(trigger, testUnit.filter)
+		_, err = s.Insert([]map[string]string{{"pk": "2", "status": "new", "region": "eu"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Update(map[string]string{"status": "done"}, map[string]string{"pk": "0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Update(map[string]string{"region": "eu"}, map[string]string{"pk": "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Delete(map[string]string{"pk": "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		equal(t, testUnit.expectedRecords, trigger.getRecords(), testUnit.testCase)
+	}
+}
+
+func TestSTable_StatementTrigger(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{
+		{"pk": "0", "f1": "v0"},
+		{"pk": "1", "f1": "v1"},
+	}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger := &testStatementTrigger{}
+	s.AddStatementTrigger(trigger)
+	_, err = s.Upsert([]map[string]string{{"pk": "1", "f1": "v11"}, {"pk": "2", "f1": "v2"}, {"pk": "3", "f1": "v3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Update(map[string]string{"f1": "v0"}, map[string]string{"pk": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Delete(map[string]string{"f1": "v0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []ChangeSet{
+		{
+			Inserted: []map[string]string{{"pk": "2", "f1": "v2"}, {"pk": "3", "f1": "v3"}},
+			Updated:  []RowUpdate{{New: map[string]string{"pk": "1", "f1": "v11"}, Old: map[string]string{"pk": "1", "f1": "v1"}}},
+		},
+		{
+			Deleted: []map[string]string{{"pk": "0", "f1": "v0"}},
+		},
+	}, trigger.changeSets, "one call per operation with changes")
+
+	trigger.err = errors.New("statement error")
+	affected, err := s.Delete(nil)
+	equal(t, 3, affected, "changes committed in spite of error")
+	equal(t, &AfterTriggerError{Err: errors.New("statement error")}, err, "statement trigger error")
+}
+
+type testStatementTrigger struct {
+	changeSets []ChangeSet
+	err        error
+}
+
+func (tt *testStatementTrigger) HandleStatement(changes ChangeSet) error {
+	tt.changeSets = append(tt.changeSets, changes)
+	return tt.err
+}
+
+func TestSTable_ChangeTrigger(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "f1": "v0", "f2": "v2"}}, "pk", nil, nil, WithName("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, "test", s.Name(), "name")
+	var changes []Change
+	s.AddChangeTrigger(ChangeTriggerFunc(func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	}))
+	var operations []string
+	s.AddTrigger(TriggerFunc(func(operation int, new, old map[string]string) error {
+		operations = append(operations, Operation(operation).String())
+		return nil
+	}))
+	_, err = s.Upsert([]map[string]string{{"pk": "0", "f1": "v1", "f3": "v3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Delete(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, []Change{
+		{
+			Operation:     OperationUpdate,
+			New:           map[string]string{"pk": "0", "f1": "v1", "f2": "v2", "f3": "v3"},
+			Old:           map[string]string{"pk": "0", "f1": "v0", "f2": "v2"},
+			ChangedFields: []string{"f1", "f3"},
+			Table:         "test",
+		},
+		{
+			Operation:     OperationDelete,
+			Old:           map[string]string{"pk": "0", "f1": "v1", "f2": "v2", "f3": "v3"},
+			ChangedFields: []string{"f1", "f2", "f3", "pk"},
+			Table:         "test",
+		},
+	}, changes, "changes")
+	equal(t, []string{"UPDATE", "DELETE"}, operations, "operations")
+}
+
+func TestOperation_String(t *testing.T) {
+	t.Parallel()
+	equal(t, "INSERT", Operation(OperationInsert).String(), "insert")
+	equal(t, "UPDATE", Operation(OperationUpdate).String(), "update")
+	equal(t, "DELETE", Operation(OperationDelete).String(), "delete")
+	equal(t, "Operation(5)", Operation(5).String(), "unknown")
+}
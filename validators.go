@@ -2,10 +2,13 @@ package stable
 
 import (
 	"fmt"
+	"time"
 )
 
 type validator interface {
 	isValid(rows []map[string]string) error
+	// invalidRow returns index of the first invalid row with the error, -1 is returned for valid rows.
+	invalidRow(rows []map[string]string) (int, error)
 }
 
 type valueEmptyValidator struct {
@@ -19,13 +22,18 @@ func newValueEmptyValidator(field string) validator {
 }
 
 func (f *valueEmptyValidator) isValid(rows []map[string]string) error {
-	for _, row := range rows {
+	_, err := f.invalidRow(rows)
+	return err
+}
+
+func (f *valueEmptyValidator) invalidRow(rows []map[string]string) (int, error) {
+	for i, row := range rows {
 		value := row[f.field]
 		if value == "" {
-			return fmt.Errorf("empty value for field \"%v\"", f.field)
+			return i, fmt.Errorf("empty value for field \"%v\"", f.field)
 		}
 	}
-	return nil
+	return -1, nil
 }
 
 type valueDuplicatesValidator struct {
@@ -39,16 +47,49 @@ func newValueDuplicatesValidator(field string) validator {
 }
 
 func (f *valueDuplicatesValidator) isValid(rows []map[string]string) error {
+	_, err := f.invalidRow(rows)
+	return err
+}
+
+func (f *valueDuplicatesValidator) invalidRow(rows []map[string]string) (int, error) {
 	find := make(map[string]struct{})
-	for _, row := range rows {
+	for i, row := range rows {
 		value := row[f.field]
 		if value == "" {
 			continue
 		}
 		if _, ok := find[value]; ok {
-			return fmt.Errorf("duplicate value \"%v\" for field \"%v\"", value, f.field)
+			return i, fmt.Errorf("duplicate value \"%v\" for field \"%v\"", value, f.field)
 		}
 		find[value] = struct{}{}
 	}
-	return nil
+	return -1, nil
+}
+
+type valueTimeValidator struct {
+	field string
+}
+
+func newValueTimeValidator(field string) validator {
+	return &valueTimeValidator{
+		field: field,
+	}
+}
+
+func (f *valueTimeValidator) isValid(rows []map[string]string) error {
+	_, err := f.invalidRow(rows)
+	return err
+}
+
+func (f *valueTimeValidator) invalidRow(rows []map[string]string) (int, error) {
+	for i, row := range rows {
+		value := row[f.field]
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+			return i, fmt.Errorf("invalid time \"%v\" for field \"%v\"", value, f.field)
+		}
+	}
+	return -1, nil
 }
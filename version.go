@@ -0,0 +1,60 @@
+package stable
+
+import (
+	"context"
+	"fmt"
+)
+
+// ConflictError is returned by STable.UpdateIfVersion when row has other version than expected.
+type ConflictError struct {
+	PK string
+	// Expected is the version passed by caller.
+	Expected uint64
+	// Actual is the current version of row, it is zero when row is not found.
+	Actual uint64
+}
+
+func (e *ConflictError) Error() string {
+	if e.Actual == 0 {
+		return fmt.Sprintf("version conflict: row \"%v\" is not found, expected version %v", e.PK, e.Expected)
+	}
+	return fmt.Sprintf("version conflict: row \"%v\" has version %v, expected version %v", e.PK, e.Actual, e.Expected)
+}
+
+func (st *stable) GetVersion(pk string) (map[string]string, uint64, bool) {
+	st.RLock()
+	defer st.RUnlock()
+	row := st.getVisible(pk)
+	if row == nil {
+		return nil, 0, false
+	}
+	st.touch(row)
+	return copyRow(row), st.meta[pk].version, true
+}
+
+func (st *stable) UpdateIfVersion(pk string, version uint64, fields map[string]string) (uint64, error) {
+	st.Lock()
+	defer st.Unlock()
+	var actual uint64
+	if row := st.getVisible(pk); row != nil {
+		actual = st.meta[pk].version
+	}
+	if actual == 0 || actual != version {
+		return actual, &ConflictError{PK: pk, Expected: version, Actual: actual}
+	}
+	_, err := st.update(context.Background(), fields, whereCondition{st.primaryKeyField: pk})
+	if _, ok := err.(*AfterTriggerError); err != nil && !ok {
+		return actual, err
+	}
+	return st.meta[pk].version, err
+}
+
+// getVisible returns committed row by primary key, nil is returned when row is not found or expired.
+func (st *stable) getVisible(pk string) map[string]string {
+	for _, row := range st.visibleRows() {
+		if row[st.primaryKeyField] == pk {
+			return row
+		}
+	}
+	return nil
+}
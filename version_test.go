@@ -0,0 +1,215 @@
+package stable
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestSTable_GetVersion(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "f1": "v0"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type testTableData struct {
+		testCase        string
+		write           func() error
+		pk              string
+		expectedRow     map[string]string
+		expectedVersion uint64
+		expectedOk      bool
+	}
+	testTable := []testTableData{
+		{
+			testCase:        "initial row",
+			write:           func() error { return nil },
+			pk:              "0",
+			expectedRow:     map[string]string{"pk": "0", "f1": "v0"},
+			expectedVersion: 1,
+			expectedOk:      true,
+		},
+		{
+			testCase: "upsert",
+			write: func() error {
+				_, err := s.Upsert([]map[string]string{{"pk": "0", "f1": "v1"}, {"pk": "1"}})
+				return err
+			},
+			pk:              "0",
+			expectedRow:     map[string]string{"pk": "0", "f1": "v1"},
+			expectedVersion: 2,
+			expectedOk:      true,
+		},
+		{
+			testCase: "update without changes",
+			write: func() error {
+				_, err := s.Update(map[string]string{"f1": "v1"}, nil)
+				return err
+			},
+			pk:              "0",
+			expectedRow:     map[string]string{"pk": "0", "f1": "v1"},
+			expectedVersion: 2,
+			expectedOk:      true,
+		},
+		{
+			testCase: "row inserted by upsert",
+			write: func() error {
+				_, err := s.Update(map[string]string{"f1": "v2"}, nil)
+				return err
+			},
+			pk:              "1",
+			expectedRow:     map[string]string{"pk": "1", "f1": "v2"},
+			expectedVersion: 6,
+			expectedOk:      true,
+		},
+		{
+			testCase: "reinserted row",
+			write: func() error {
+				_, err := s.Delete(map[string]string{"pk": "0"})
+				if err != nil {
+					return err
+				}
+				_, err = s.Insert([]map[string]string{{"pk": "0"}})
+				return err
+			},
+			pk:              "0",
+			expectedRow:     map[string]string{"pk": "0"},
+			expectedVersion: 7,
+			expectedOk:      true,
+		},
+		{
+			testCase:        "not found",
+			write:           func() error { return nil },
+			pk:              "2",
+			expectedRow:     nil,
+			expectedVersion: 0,
+			expectedOk:      false,
+		},
+	}
+	for _, testUnit := range testTable {
+		err = testUnit.write()
+		if err != nil {
+			t.Fatal(err)
+		}
+		row, version, ok := s.GetVersion(testUnit.pk)
+		equal(t, testUnit.expectedRow, row, testUnit.testCase)
+		equal(t, testUnit.expectedVersion, version, testUnit.testCase)
+		equal(t, testUnit.expectedOk, ok, testUnit.testCase)
+	}
+}
+
+func TestSTable_UpdateIfVersion(t *testing.T) {
+	t.Parallel()
+	type testTableData struct {
+		testCase         string
+		pk               string
+		version          uint64
+		fields           map[string]string
+		expectedVersion  uint64
+		expectedErr      error
+		expectedSelected []map[string]string
+	}
+	testTable := []testTableData{
+		{
+			testCase:         "update",
+			pk:               "0",
+			version:          2,
+			fields:           map[string]string{"f1": "v2"},
+			expectedVersion:  3,
+			expectedErr:      nil,
+			expectedSelected: []map[string]string{{"pk": "0", "f1": "v2"}},
+		},
+		{
+			testCase:         "stale version",
+			pk:               "0",
+			version:          1,
+			fields:           map[string]string{"f1": "v2"},
+			expectedVersion:  2,
+			expectedErr:      &ConflictError{PK: "0", Expected: 1, Actual: 2},
+			expectedSelected: []map[string]string{{"pk": "0", "f1": "v1"}},
+		},
+		{
+			testCase:         "not found",
+			pk:               "1",
+			version:          1,
+			fields:           map[string]string{"f1": "v2"},
+			expectedVersion:  0,
+			expectedErr:      &ConflictError{PK: "1", Expected: 1, Actual: 0},
+			expectedSelected: []map[string]string{{"pk": "0", "f1": "v1"}},
+		},
+		{
+			testCase:         "constraints",
+			pk:               "0",
+			version:          2,
+			fields:           map[string]string{"f1": ""},
+			expectedVersion:  2,
+			expectedErr:      errors.New("empty value for field \"f1\""),
+			expectedSelected: []map[string]string{{"pk": "0", "f1": "v1"}},
+		},
+	}
+	for _, testUnit := range testTable {
+		s, err := NewSTable([]map[string]string{{"pk": "0", "f1": "v0"}}, "pk", []string{"f1"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Update(map[string]string{"f1": "v1"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version, err := s.UpdateIfVersion(testUnit.pk, testUnit.version, testUnit.fields)
+		equal(t, testUnit.expectedVersion, version, testUnit.testCase)
+		equal(t, testUnit.expectedErr, err, testUnit.testCase)
+		equal(t, testUnit.expectedSelected, s.Find(nil), testUnit.testCase)
+	}
+}
+
+func TestSTable_UpdateIfVersionReinserted(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "f1": "v0"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, version, _ := s.GetVersion("0")
+	_, err = s.Delete(map[string]string{"pk": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0", "f1": "v1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, actual, _ := s.GetVersion("0")
+	equal(t, true, actual != version, "reinserted row has new version")
+	_, err = s.UpdateIfVersion("0", version, map[string]string{"f1": "v2"})
+	equal(t, &ConflictError{PK: "0", Expected: version, Actual: actual}, err, "version of deleted row")
+	equal(t, []map[string]string{{"pk": "0", "f1": "v1"}}, s.Find(nil), "reinserted row is not updated")
+}
+
+func TestSTable_UpdateIfVersionConcurrent(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "counter": "0"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conflicts := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, version, _ := s.GetVersion("0")
+			_, err := s.UpdateIfVersion("0", version, map[string]string{"counter": strconv.Itoa(i)})
+			var conflictErr *ConflictError
+			if errors.As(err, &conflictErr) {
+				mu.Lock()
+				conflicts++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	_, version, _ := s.GetVersion("0")
+	equal(t, uint64(11-conflicts), version, "every successful update increases version")
+}
@@ -0,0 +1,69 @@
+package stable
+
+import (
+	"context"
+)
+
+type waiter struct {
+	where map[string]string
+	row   chan map[string]string
+}
+
+func (st *stable) WaitFor(ctx context.Context, where map[string]string) (map[string]string, error) {
+	err := st.lockContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row := st.selectAny(where)
+	if row != nil {
+		st.Unlock()
+		return row, nil
+	}
+	w := &waiter{where: copyRow(where), row: make(chan map[string]string, 1)}
+	st.waiters = append(st.waiters, w)
+	st.Unlock()
+
+	select {
+	case row = <-w.row:
+		return row, nil
+	case <-ctx.Done():
+	}
+	st.Lock()
+	st.removeWaiter(w)
+	st.Unlock()
+	select {
+	case row = <-w.row:
+		// matched row was committed before waiter removal
+		return row, nil
+	default:
+		return nil, ctx.Err()
+	}
+}
+
+func (st *stable) removeWaiter(w *waiter) {
+	for i, waiter := range st.waiters {
+		if waiter == w {
+			st.waiters = append(st.waiters[:i:i], st.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyWaiters passes rows produced by committed changes to matched waiters and removes them.
+func (st *stable) notifyWaiters(changes []Change) {
+	if len(st.waiters) == 0 || len(changes) == 0 {
+		return
+	}
+	waiters := st.waiters[:0:0]
+waitersLoop:
+	for _, w := range st.waiters {
+		for _, change := range changes {
+			if change.New != nil && matches(change.New, w.where) {
+				w.row <- copyRow(change.New)
+				continue waitersLoop
+			}
+		}
+		waiters = append(waiters, w)
+	}
+	st.waiters = waiters
+}
@@ -0,0 +1,74 @@
+package stable
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSTable_WaitFor(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable([]map[string]string{{"pk": "0", "state": "ready"}, {"pk": "1", "state": "new"}}, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	row, err := s.WaitFor(ctx, map[string]string{"state": "ready"})
+	equal(t, map[string]string{"pk": "0", "state": "ready"}, row, "existing row")
+	equal(t, nil, err, "existing row error")
+
+	type result struct {
+		row map[string]string
+		err error
+	}
+	results := make(chan result)
+	go func() {
+		row, err := s.WaitFor(ctx, map[string]string{"state": "done"})
+		results <- result{row: row, err: err}
+	}()
+	time.Sleep(5 * time.Millisecond)
+	_, err = s.Update(map[string]string{"state": "ready"}, map[string]string{"pk": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Update(map[string]string{"state": "done"}, map[string]string{"pk": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, result{row: map[string]string{"pk": "1", "state": "done"}}, <-results, "committed row")
+
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancelTimeout()
+	row, err = s.WaitFor(timeout, map[string]string{"state": "failed"})
+	equal(t, map[string]string(nil), row, "timeout row")
+	equal(t, context.DeadlineExceeded, err, "timeout error")
+}
+
+func TestSTable_WaitForWhereCopy(t *testing.T) {
+	t.Parallel()
+	s, err := NewSTable(nil, "pk", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	where := map[string]string{"state": "done"}
+	rows := make(chan map[string]string)
+	go func() {
+		row, _ := s.WaitFor(ctx, where)
+		rows <- row
+	}()
+	st := s.(*stable)
+	for waiting := false; !waiting; {
+		st.Lock()
+		waiting = len(st.waiters) > 0
+		st.Unlock()
+	}
+	where["state"] = "failed"
+	_, err = s.Insert([]map[string]string{{"pk": "0", "state": "done"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, map[string]string{"pk": "0", "state": "done"}, <-rows, "waiter keeps conditions")
+}
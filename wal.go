@@ -0,0 +1,341 @@
+package stable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+)
+
+// SyncPolicy defines when WAL is flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes WAL after every record, committed changes survive crash of the machine.
+	SyncAlways SyncPolicy = iota
+	// SyncNever leaves flushing to the operating system, committed changes survive crash of the process.
+	SyncNever
+)
+
+// walHeaderSize is the size of record header: payload length and CRC32 of payload.
+const walHeaderSize = 8
+
+// WAL is append-only write-ahead log of STable changes, see WithWAL.
+// Every record holds changes of one operation and is framed with its length and checksum,
+// so torn final record of a crashed process is detected and ignored.
+type WAL struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	policy SyncPolicy
+	size   int64
+}
+
+type walRecord struct {
+	// Seq is the sequence number of the first change.
+	Seq     uint64      `json:"seq"`
+	Changes []walChange `json:"changes"`
+}
+
+type walChange struct {
+	Operation Operation `json:"op"`
+	PK        string    `json:"pk"`
+	// Row is the new row for OperationInsert and OperationUpdate.
+	Row map[string]string `json:"row,omitempty"`
+}
+
+// OpenWAL opens or creates write-ahead log at path.
+// Torn final record is cut off, so new records follow the last complete one.
+func OpenWAL(path string, policy SyncPolicy) (*WAL, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAL{path: path, file: file, policy: policy}
+	size, err := w.scan(nil)
+	if err == nil {
+		err = w.truncate(size)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Close flushes and closes the log file.
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.file.Sync()
+	if err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// Size returns size of the log in bytes.
+func (w *WAL) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// append writes record to the end of the log.
+// Partially written record is cut off, so the log stays consistent on error.
+func (w *WAL) append(record walRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.file.WriteAt(buf, w.size)
+	if err == nil && w.policy == SyncAlways {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		_ = w.file.Truncate(w.size)
+		return err
+	}
+	w.size += int64(len(buf))
+	return nil
+}
+
+// truncate cuts the log off at size.
+func (w *WAL) truncate(size int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.file.Truncate(size)
+	if err != nil {
+		return err
+	}
+	w.size = size
+	return nil
+}
+
+// compact removes records of changes with sequence numbers up to seq from the log.
+// Remaining records are written to a new file which replaces the log.
+func (w *WAL) compact(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	tmpPath := w.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var size int64
+	_, err = w.scanLocked(func(record walRecord, frame []byte) error {
+		if record.Seq <= seq {
+			return nil
+		}
+		n, err := file.Write(frame)
+		size += int64(n)
+		return err
+	})
+	if err == nil {
+		err = file.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, w.path)
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	_ = w.file.Close()
+	w.file = file
+	w.size = size
+	return nil
+}
+
+// scan reads records of the log from the beginning and passes them to fn when it is not nil.
+// It returns size of the log without torn final record.
+func (w *WAL) scan(fn func(record walRecord, frame []byte) error) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.scanLocked(fn)
+}
+
+func (w *WAL) scanLocked(fn func(record walRecord, frame []byte) error) (int64, error) {
+	info, err := w.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	total := info.Size()
+	r := bufio.NewReader(io.NewSectionReader(w.file, 0, total))
+	var offset int64
+	header := make([]byte, walHeaderSize)
+	for {
+		_, err = io.ReadFull(r, header)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return offset, nil // torn header or end of log
+		}
+		if err != nil {
+			return 0, err
+		}
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		end := offset + walHeaderSize + length
+		if end > total {
+			// payload of torn record is cut by the end of log, corrupt length hides records following it
+			rest, err := io.ReadAll(r)
+			if err != nil {
+				return 0, err
+			}
+			if containsFrame(rest) {
+				return 0, fmt.Errorf("corrupt write-ahead log record at offset %v", offset)
+			}
+			return offset, nil
+		}
+		frame := make([]byte, walHeaderSize+length)
+		copy(frame, header)
+		payload := frame[walHeaderSize:]
+		_, err = io.ReadFull(r, payload)
+		if err != nil {
+			return 0, err
+		}
+		if length == 0 || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			torn, err := isTornTail(r, end == total)
+			if err != nil {
+				return 0, err
+			}
+			if torn {
+				return offset, nil
+			}
+			return 0, fmt.Errorf("corrupt write-ahead log record at offset %v", offset)
+		}
+		if fn != nil {
+			var record walRecord
+			err = json.Unmarshal(payload, &record)
+			if err != nil {
+				return 0, fmt.Errorf("corrupt write-ahead log record at offset %v: %v", offset, err)
+			}
+			err = fn(record, frame)
+			if err != nil {
+				return 0, err
+			}
+		}
+		offset = end
+	}
+}
+
+// isTornTail reports whether invalid record is torn final record:
+// it is the last one or only zeros left by interrupted write follow it.
+func isTornTail(r io.Reader, last bool) (bool, error) {
+	if last {
+		return true, nil
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		return false, err
+	}
+	for _, b := range rest {
+		if b != 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// containsFrame reports whether a valid record starts anywhere in data.
+func containsFrame(data []byte) bool {
+	for i := 0; i+walHeaderSize < len(data); i++ {
+		length := int64(binary.BigEndian.Uint32(data[i : i+4]))
+		end := int64(i) + walHeaderSize + length
+		// payload is a JSON object
+		if length == 0 || end > int64(len(data)) || data[i+walHeaderSize] != '{' {
+			continue
+		}
+		if crc32.ChecksumIEEE(data[i+walHeaderSize:end]) == binary.BigEndian.Uint32(data[i+4:i+8]) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAhead appends changes to be committed to the write-ahead log.
+func (st *stable) writeAhead(changes []Change) error {
+	if st.wal == nil || len(changes) == 0 {
+		return nil
+	}
+	record := walRecord{Seq: changes[0].Seq, Changes: make([]walChange, len(changes))}
+	for i, change := range changes {
+		wc := walChange{Operation: change.Operation, Row: change.New}
+		if change.Operation == OperationDelete {
+			wc.PK = change.Old[st.primaryKeyField]
+		} else {
+			wc.PK = change.New[st.primaryKeyField]
+		}
+		record.Changes[i] = wc
+	}
+	return st.wal.append(record)
+}
+
+// replay applies changes from the write-ahead log which follow the last committed change.
+// Triggers are not called for replayed changes.
+func (st *stable) replay() error {
+	if st.wal == nil {
+		return nil
+	}
+	rows := st.getRowsCopy()
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		index[row[st.primaryKeyField]] = i
+	}
+	seq := st.seq
+	_, err := st.wal.scan(func(record walRecord, _ []byte) error {
+		for i, change := range record.Changes {
+			changeSeq := record.Seq + uint64(i)
+			if changeSeq <= seq {
+				continue
+			}
+			if changeSeq != seq+1 {
+				return errors.New("write-ahead log does not continue the initial state")
+			}
+			seq = changeSeq
+			pos, ok := index[change.PK]
+			switch {
+			case change.Operation == OperationDelete:
+				if ok {
+					rows[pos] = nil
+					delete(index, change.PK)
+				}
+			case ok:
+				rows[pos] = change.Row
+			default:
+				index[change.PK] = len(rows)
+				rows = append(rows, change.Row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	replayed := make([]map[string]string, 0, len(index))
+	for _, row := range rows {
+		if row != nil {
+			replayed = append(replayed, row)
+		}
+	}
+	err = st.validateRows(replayed)
+	if err != nil {
+		return err
+	}
+	st.rows = replayed
+	st.seq = seq
+	st.resetMeta()
+	return nil
+}
@@ -0,0 +1,153 @@
+package stable
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAL_Recovery(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "wal")
+	wal, err := OpenWAL(path, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSTable(nil, "pk", nil, []string{"uniq"}, WithWAL(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0", "uniq": "u0"}, {"pk": "1", "uniq": "u1"}, {"pk": "2", "uniq": "u2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "3", "uniq": "u0"}})
+	equal(t, errors.New("duplicate value \"u0\" for field \"uniq\""), err, "rejected operation")
+	_, err = s.Update(map[string]string{"f1": "v1"}, map[string]string{"pk": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Delete(map[string]string{"pk": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, nil, wal.Close(), "close")
+
+	expected := []map[string]string{{"pk": "1", "uniq": "u1", "f1": "v1"}, {"pk": "2", "uniq": "u2"}}
+	wal, err = OpenWAL(path, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := NewSTable(nil, "pk", nil, []string{"uniq"}, WithWAL(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, expected, recovered.Find(nil), "recovered rows")
+	equal(t, uint64(5), recovered.LastSeq(), "recovered seq")
+
+	var snapshot bytes.Buffer
+	_, err = recovered.WriteTo(&snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = recovered.Insert([]map[string]string{{"pk": "4", "uniq": "u4"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, nil, wal.Close(), "close")
+
+	wal, err = OpenWAL(path, SyncAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	loaded, err := Load(&snapshot, WithWAL(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equal(t, append(expected, map[string]string{"pk": "4", "uniq": "u4"}), loaded.Find(nil), "snapshot with replayed changes")
+	equal(t, uint64(6), loaded.LastSeq(), "snapshot seq")
+}
+
+func TestWAL_TornRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "wal")
+	wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSTable(nil, "pk", nil, nil, WithWAL(wal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Insert([]map[string]string{{"pk": "0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := wal.Size()
+	_, err = s.Insert([]map[string]string{{"pk": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := wal.Size()
+	equal(t, nil, wal.Close(), "close")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeros := append(append([]byte{}, data[:size]...), make([]byte, full-size)...)
+	for _, torn := range [][]byte{data[:size+3], data[:full-1], zeros} {
+		err = os.WriteFile(path, torn, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wal, err = OpenWAL(path, SyncNever)
+		if err != nil {
+			t.Fatal(err)
+		}
+		equal(t, size, wal.Size(), "torn record is cut off")
+		recovered, err := NewSTable(nil, "pk", nil, nil, WithWAL(wal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		equal(t, []map[string]string{{"pk": "0"}}, recovered.Find(nil), "torn record is ignored")
+		equal(t, nil, wal.Close(), "close")
+	}
+
+	corruptLength := append([]byte{}, data...)
+	corruptLength[0] = 0xff
+	err = os.WriteFile(path, corruptLength, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenWAL(path, SyncNever)
+	equal(t, errors.New("corrupt write-ahead log record at offset 0"), err, "corrupt length")
+
+	data[size-1] ^= 0xff
+	data = append(data, data...)
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenWAL(path, SyncNever)
+	equal(t, errors.New("corrupt write-ahead log record at offset 0"), err, "corrupt record")
+}
+
+func TestWAL_Discontinuity(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "wal")
+	wal, err := OpenWAL(path, SyncNever)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Close()
+	err = wal.append(walRecord{Seq: 3, Changes: []walChange{{Operation: OperationInsert, PK: "0", Row: map[string]string{"pk": "0"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewSTable(nil, "pk", nil, nil, WithWAL(wal))
+	equal(t, errors.New("write-ahead log does not continue the initial state"), err, "missing changes")
+}